@@ -44,3 +44,70 @@ func (q *NodePriorityQueue) Pop() interface{} {
 	*q = old[0 : n-1]
 	return x
 }
+
+// lanes keeps the ordered lanes of the active nodes.
+// Each lane has a slot which never moves, and the live slots are counted with a Fenwick tree,
+// so that finding the index of a lane does not need to scan the lanes on its left.
+type lanes struct {
+	slots map[string]int
+	tree  []int
+	next  int
+}
+
+// newLanes returns lanes which can hold size lanes opened in total before growing.
+func newLanes(size int) *lanes {
+	return &lanes{
+		slots: make(map[string]int),
+		tree:  make([]int, size+1),
+	}
+}
+
+func (l *lanes) contains(hash string) bool {
+	_, ok := l.slots[hash]
+	return ok
+}
+
+// append opens a new lane on the right.
+func (l *lanes) append(hash string) {
+	if l.next+1 >= len(l.tree) {
+		l.grow()
+	}
+	l.slots[hash] = l.next
+	l.add(l.next, 1)
+	l.next++
+}
+
+// replace hands over the lane of old to new.
+func (l *lanes) replace(old, new string) {
+	l.slots[new] = l.slots[old]
+	delete(l.slots, old)
+}
+
+// remove closes the lane, so the lanes on its right move to the left.
+func (l *lanes) remove(hash string) {
+	l.add(l.slots[hash], -1)
+	delete(l.slots, hash)
+}
+
+// index returns the number of the lanes on the left of the lane of hash.
+func (l *lanes) index(hash string) int {
+	n := 0
+	for i := l.slots[hash]; i > 0; i -= i & -i {
+		n += l.tree[i]
+	}
+	return n
+}
+
+// grow doubles the slots for the rows read after the lanes are created, rebuilding the tree from the live slots.
+func (l *lanes) grow() {
+	l.tree = make([]int, 2*len(l.tree))
+	for _, slot := range l.slots {
+		l.add(slot, 1)
+	}
+}
+
+func (l *lanes) add(slot, delta int) {
+	for i := slot + 1; i < len(l.tree); i += i & -i {
+		l.tree[i] += delta
+	}
+}
@@ -18,34 +18,58 @@ type Edge struct {
 type Edges []*Edge
 
 func calculateEdges(repo *Repository) {
-	edges := make(map[int]Edges)
-	for i := range repo.Nodes {
-		edges[i] = make(Edges, 0)
+	repo.edgesMap = make(map[int]Edges)
+	appendEdges(repo, repo.Nodes, 0, len(repo.Nodes))
+}
+
+// appendEdges appends the edges drawn by ns to the rows in [from, to).
+// ns must be sorted by row, so that each row gets its edges in the same order as when all rows are calculated at once.
+func appendEdges(repo *Repository, ns Nodes, from, to int) {
+	edges := repo.edgesMap
+	for y := from; y < to; y++ {
+		edges[y] = make(Edges, 0)
+	}
+	add := func(y int, e *Edge) {
+		if from <= y && y < to {
+			edges[y] = append(edges[y], e)
+		}
+	}
+	// straight adds the straight edges to the rows between above and below, excluding both
+	straight := func(above, below, posX int) {
+		if above < from-1 {
+			above = from - 1
+		}
+		if below > to {
+			below = to
+		}
+		for y := above + 1; y < below; y++ {
+			edges[y] = append(edges[y], &Edge{EdgeStraight, posX})
+		}
 	}
-	for _, n := range repo.Nodes {
+	for _, n := range ns {
 		h := n.Hash()
-		for _, child := range repo.Children(h) {
-			edges[n.PosY()] = append(edges[n.PosY()], &Edge{EdgeUp, n.PosX()})
+		for _, child := range repo.children(h) {
+			if repo.sort == Chronological && child.PosY() > n.PosY() {
+				// the children below are read after the row is laid out if the history is streamed
+				continue
+			}
+			add(n.PosY(), &Edge{EdgeUp, n.PosX()})
 			if n.PosX() == child.PosX() {
-				for y := n.PosY() - 1; y > child.PosY(); y-- {
-					edges[y] = append(edges[y], &Edge{EdgeStraight, n.PosX()})
-				}
+				straight(child.PosY(), n.PosY(), n.PosX())
 			} else if n.PosX() < child.PosX() {
-				edges[n.PosY()] = append(edges[n.PosY()], &Edge{EdgeBranch, child.PosX()})
-				for y := n.PosY() - 1; y > child.PosY(); y-- {
-					edges[y] = append(edges[y], &Edge{EdgeStraight, child.PosX()})
-				}
+				add(n.PosY(), &Edge{EdgeBranch, child.PosX()})
+				straight(child.PosY(), n.PosY(), child.PosX())
 			}
 		}
-		for _, parent := range repo.Parents(h) {
-			edges[n.PosY()] = append(edges[n.PosY()], &Edge{EdgeDown, n.PosX()})
+		for _, parent := range repo.parents(h) {
+			if repo.sort == Chronological && parent.PosY() < n.PosY() {
+				continue
+			}
+			add(n.PosY(), &Edge{EdgeDown, n.PosX()})
 			if n.PosX() < parent.PosX() {
-				edges[n.PosY()] = append(edges[n.PosY()], &Edge{EdgeMerge, parent.PosX()})
-				for y := n.PosY() + 1; y < parent.PosY(); y++ {
-					edges[y] = append(edges[y], &Edge{EdgeStraight, parent.PosX()})
-				}
+				add(n.PosY(), &Edge{EdgeMerge, parent.PosX()})
+				straight(n.PosY(), parent.PosY(), parent.PosX())
 			}
 		}
 	}
-	repo.edgesMap = edges
 }
@@ -1,32 +1,92 @@
 package gogigu
 
 import (
+	"container/heap"
+	"context"
+	"io"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	// WorktreeHash is the pseudo hash of the node which represents the uncommitted changes in the worktree.
+	WorktreeHash = "0000000000000000000000000000000000000000"
+
+	worktreeMessage = "Uncommitted changes"
+
+	// progressInterval is the number of commits read between progress reports.
+	progressInterval = 1000
+
+	// excludeSlop is the number of the commits walked after all the commits left are excluded.
+	excludeSlop = 5
 )
 
 type Repository struct {
+	// Nodes must not be read directly while the history is streamed. Use Len, NodeAt and AllNodes instead.
 	Nodes Nodes
 
 	nodesMap    map[string]*Node
 	childrenMap map[string]Nodes
 	parentsMap  map[string]Nodes
-	edgesMap    map[int]Edges
-	maxPosX     int
+	// waiting are the nodes whose parents of the hash are not added yet
+	waiting  map[string]Nodes
+	edgesMap map[int]Edges
+	maxPosX  int
+	lazy     *lazyLayout
+	sort     Sort
 }
 
+// lock guards the graph if it is laid out or read lazily, and returns the function to unlock it.
+func (r *Repository) lock() func() {
+	l := r.lazy
+	if l == nil {
+		return func() {}
+	}
+	l.mu.Lock()
+	return l.mu.Unlock
+}
+
+// MaxPosX returns the max posX of the rows laid out so far, which may grow as EnsureLayout is called.
+// It is safe to call while EnsureLayout runs on another goroutine, like the other methods reading the graph.
 func (r *Repository) MaxPosX() int {
+	defer r.lock()()
 	return r.maxPosX
 }
 
+// Len returns the number of the rows read so far, which may grow as EnsureLayout is called if the history is streamed.
+func (r *Repository) Len() int {
+	defer r.lock()()
+	return len(r.Nodes)
+}
+
+// NodeAt returns the node of the row of posY, which must be less than Len.
+func (r *Repository) NodeAt(posY int) *Node {
+	defer r.lock()()
+	return r.Nodes[posY]
+}
+
+// Node returns nil if the commit is not in the graph, or not read yet if the history is streamed.
 func (r *Repository) Node(hash string) *Node {
+	defer r.lock()()
+	return r.node(hash)
+}
+
+func (r *Repository) node(hash string) *Node {
 	return r.nodesMap[hash]
 }
 
 func (r *Repository) Children(hash string) Nodes {
+	defer r.lock()()
+	return r.children(hash)
+}
+
+func (r *Repository) children(hash string) Nodes {
 	children, ok := r.childrenMap[hash]
 	if ok {
 		return children
@@ -44,6 +104,11 @@ func (r *Repository) ChildrenHashes(hash string) []string {
 }
 
 func (r *Repository) Parents(hash string) Nodes {
+	defer r.lock()()
+	return r.parents(hash)
+}
+
+func (r *Repository) parents(hash string) Nodes {
 	parents, ok := r.parentsMap[hash]
 	if ok {
 		return parents
@@ -60,7 +125,9 @@ func (r *Repository) ParentsHashes(hash string) []string {
 	return ret
 }
 
+// Edges returns the edges of the row of posY, which must have been laid out by EnsureLayout if the repository is laid out lazily.
 func (r *Repository) Edges(posY int) []*Edge {
+	defer r.lock()()
 	edges, ok := r.edgesMap[posY]
 	if ok {
 		return edges
@@ -68,61 +135,454 @@ func (r *Repository) Edges(posY int) []*Edge {
 	return []*Edge{}
 }
 
-func initRepository(repo *git.Repository) (*Repository, error) {
-	cIter, err := repo.Log(&git.LogOptions{All: true, Order: git.LogOrderCommitterTime})
-	if err != nil {
-		return nil, err
-	}
-
+func initRepository(ctx context.Context, repo *git.Repository, opt *Option, progress Progress) (*Repository, error) {
 	nodes := make(Nodes, 0)
-	nodesMap := make(map[string]*Node)
 
-	err = cIter.ForEach(func(c *object.Commit) error {
-		hash := c.Hash.String()
+	read := func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		n := &Node{
 			Commit: c,
-			hash:   hash,
+			hash:   c.Hash.String(),
 		}
 		nodes = append(nodes, n)
-		nodesMap[hash] = n
+		if len(nodes)%progressInterval == 0 {
+			progress(ReadingCommits, len(nodes))
+		}
+		return nil
+	}
+	var err error
+	switch {
+	case opt.Sort == Chronological:
+		err = readChronologicalCommits(ctx, repo, opt, read)
+	case len(opt.From) == 0:
+		err = readAllCommits(repo, read)
+	default:
+		err = readReachableCommits(ctx, repo, opt.From, opt.Exclude, read)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Worktree {
+		head, err := repo.Head()
+		// HEAD may be out of the commits read if they are limited
+		if err == nil && includesCommit(nodes, head.Hash()) {
+			nodes = append(nodes, newWorktreeNode(head.Hash()))
+		} else if err != nil && err != plumbing.ErrReferenceNotFound {
+			return nil, err
+		}
+	}
+
+	// the chronological walk follows only the first parents by itself
+	if opt.FirstParent && opt.Sort != Chronological {
+		tips := opt.From
+		if len(tips) == 0 {
+			tips, err = refTips(repo)
+			if err != nil {
+				return nil, err
+			}
+		}
+		nodes = firstParentNodes(nodes, tips)
+	}
+
+	parentHashes := graphParentHashes(opt)
+	r := newRepository(nodes, parentHashes)
+	// the boundary commits of the revisions shown lack their parents by design
+	if len(opt.From) == 0 {
+		logMissingParents(r, parentHashes)
+	}
+	return r, nil
+}
+
+// graphParentHashes returns the parents of the nodes in the graph, which are only the first ones if opt.FirstParent is set.
+func graphParentHashes(opt *Option) func(*Node) []string {
+	return func(n *Node) []string {
+		ps := n.Commit.ParentHashes
+		if opt.FirstParent && len(ps) > 1 {
+			ps = ps[:1]
+		}
+		hs := make([]string, len(ps))
+		for i, h := range ps {
+			hs[i] = h.String()
+		}
+		return hs
+	}
+}
+
+// readChronologicalCommits calls f with the commits in Chronological order, reading them like readAllCommits or readReachableCommits.
+func readChronologicalCommits(ctx context.Context, repo *git.Repository, opt *Option, f func(*object.Commit) error) error {
+	tips := opt.From
+	seen := make(map[plumbing.Hash]struct{})
+	if len(tips) == 0 {
+		var err error
+		tips, err = refTips(repo)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		seen, err = excludedCommits(ctx, repo, tips, opt.Exclude)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := newCommitWalker(repo, repo.Storer, tips, seen, opt.FirstParent)
+	if err != nil {
+		return err
+	}
+	for {
+		c, err := w.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(c); err != nil {
+			return err
+		}
+	}
+}
+
+// readAllCommits calls f with all commits reachable from the refs.
+func readAllCommits(repo *git.Repository, f func(*object.Commit) error) error {
+	cIter, err := repo.Log(&git.LogOptions{All: true, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return err
+	}
+	return cIter.ForEach(f)
+}
+
+// readReachableCommits calls f with the commits reachable from any of from and none of exclude, like `git log from... --not exclude...`.
+func readReachableCommits(ctx context.Context, repo *git.Repository, from, exclude []plumbing.Hash, f func(*object.Commit) error) error {
+	seen, err := excludedCommits(ctx, repo, from, exclude)
+	if err != nil {
+		return err
+	}
+	return walkCommits(repo, from, seen, f)
+}
+
+// excludedCommits returns the commits reachable from exclude which a walk from from would reach, like the commits `git log` marks uninteresting.
+// The commits are walked from the newest like git, and the walk stops a few commits after all the commits left to walk are excluded
+// and older than the last one included, so it reads only the commits around the exclusions unless the commit times are skewed.
+func excludedCommits(ctx context.Context, repo *git.Repository, from, exclude []plumbing.Hash) (map[plumbing.Hash]struct{}, error) {
+	seen := make(map[plumbing.Hash]struct{})
+	if len(exclude) == 0 {
+		return seen, nil
+	}
+	read := make(map[plumbing.Hash]*object.Commit)
+	queued := make(map[plumbing.Hash]bool)
+	q := &walkQueue{}
+	// included is the number of the queued commits which are not excluded
+	included := 0
+	push := func(h plumbing.Hash) (bool, error) {
+		if _, ok := read[h]; ok {
+			return false, nil
+		}
+		c, err := readCommit(repo.Storer, repo.Storer, h)
+		if err != nil || c == nil {
+			return false, err
+		}
+		read[h] = c
+		queued[h] = true
+		heap.Push(q, &walkItem{commit: c, seq: len(read)})
+		return true, nil
+	}
+	include := func(h plumbing.Hash) error {
+		ok, err := push(h)
+		if ok {
+			included++
+		}
+		return err
+	}
+	// markExcluded excludes the ancestors walked already at once, like mark_parents_uninteresting of git
+	markExcluded := func(h plumbing.Hash) error {
+		stack := []plumbing.Hash{h}
+		for len(stack) > 0 {
+			h := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if _, ok := seen[h]; ok {
+				continue
+			}
+			c, ok := read[h]
+			if !ok {
+				ok, err := push(h)
+				if err != nil {
+					return err
+				}
+				if ok {
+					seen[h] = struct{}{}
+				}
+				continue
+			}
+			seen[h] = struct{}{}
+			if queued[h] {
+				// its parents are excluded when it is popped
+				included--
+				continue
+			}
+			stack = append(stack, c.ParentHashes...)
+		}
+		return nil
+	}
+	for _, h := range exclude {
+		if err := markExcluded(h); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range from {
+		if err := include(h); err != nil {
+			return nil, err
+		}
+	}
+	var last time.Time
+	slop := excludeSlop
+	for q.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c := heap.Pop(q).(*walkItem).commit
+		queued[c.Hash] = false
+		if _, ok := seen[c.Hash]; !ok {
+			included--
+			last = c.Committer.When
+			for _, p := range c.ParentHashes {
+				if err := include(p); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		for _, p := range c.ParentHashes {
+			if err := markExcluded(p); err != nil {
+				return nil, err
+			}
+		}
+		// like still_interesting of git, a commit newer than the last one included may still exclude the ones walked already
+		if included > 0 || (q.Len() > 0 && !(*q)[0].commit.Committer.When.Before(last)) {
+			slop = excludeSlop
+		} else if slop--; slop == 0 {
+			break
+		}
+	}
+	return seen, nil
+}
+
+// walkCommits calls f with the commits reachable from tips which are not in seen yet, and adds them to seen.
+func walkCommits(repo *git.Repository, tips []plumbing.Hash, seen map[plumbing.Hash]struct{}, f func(*object.Commit) error) error {
+	queue := make([]plumbing.Hash, 0, len(tips))
+	push := func(h plumbing.Hash) {
+		if _, ok := seen[h]; !ok {
+			seen[h] = struct{}{}
+			queue = append(queue, h)
+		}
+	}
+	for _, h := range tips {
+		push(h)
+	}
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		c, err := readCommit(repo.Storer, repo.Storer, h)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			continue
+		}
+		if err := f(c); err != nil {
+			return err
+		}
+		for _, p := range c.ParentHashes {
+			push(p)
+		}
+	}
+	return nil
+}
+
+func includesCommit(nodes Nodes, hash plumbing.Hash) bool {
+	for _, n := range nodes {
+		if n.Commit.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// refTips returns the commits which the refs point to, peeling annotated tags.
+// They are in the order of the ref names, which the chronological walk needs to be the same for every read.
+func refTips(repo *git.Repository) ([]plumbing.Hash, error) {
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]*plumbing.Reference, 0)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			refs = append(refs, ref)
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name() < refs[j].Name()
+	})
+	tips := make([]plumbing.Hash, len(refs))
+	for i, ref := range refs {
+		h, err := peelToCommit(repo, ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+		tips[i] = h
+	}
+	return tips, nil
+}
 
-	parentsMap := make(map[string]Nodes)
-	childrenMap := make(map[string]Nodes)
+// firstParentNodes returns the nodes reachable from tips and the worktree node by following only the first parents.
+func firstParentNodes(nodes Nodes, tips []plumbing.Hash) Nodes {
+	nodesMap := make(map[string]*Node)
 	for _, n := range nodes {
-		parentsMap[n.hash] = make(Nodes, 0)
-		for _, h := range n.Commit.ParentHashes {
-			parentHash := h.String()
-			if parentNode, ok := nodesMap[parentHash]; ok {
-				parentsMap[n.hash] = append(parentsMap[n.hash], parentNode)
-				if _, ok := childrenMap[parentHash]; !ok {
-					childrenMap[parentHash] = make(Nodes, 0)
-				}
-				childrenMap[parentHash] = append(childrenMap[parentHash], n)
-			} else {
+		nodesMap[n.hash] = n
+	}
+	included := make(map[string]struct{})
+	follow := func(n *Node) {
+		for n != nil {
+			if _, ok := included[n.hash]; ok {
+				return
+			}
+			included[n.hash] = struct{}{}
+			if len(n.Commit.ParentHashes) == 0 {
+				return
+			}
+			n = nodesMap[n.Commit.ParentHashes[0].String()]
+		}
+	}
+	for _, h := range tips {
+		follow(nodesMap[h.String()])
+	}
+	for _, n := range nodes {
+		if n.worktree {
+			follow(n)
+		}
+	}
+
+	ret := make(Nodes, 0, len(included))
+	for _, n := range nodes {
+		if _, ok := included[n.hash]; ok {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// readCommit reads the commit of h from s, which reads its trees and parents from bind.
+// It returns nil if s lacks the commit, like the parents of the oldest commits in a shallow clone.
+func readCommit(s, bind storer.EncodedObjectStorer, h plumbing.Hash) (*object.Commit, error) {
+	obj, err := s.EncodedObject(plumbing.CommitObject, h)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return object.DecodeCommit(bind, obj)
+}
+
+// peelToCommit returns the hash of the object which the annotated tags point to, or h itself if it is not a tag.
+func peelToCommit(repo *git.Repository, h plumbing.Hash) (plumbing.Hash, error) {
+	for {
+		t, err := repo.TagObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			return h, nil
+		}
+		if err != nil {
+			return h, err
+		}
+		h = t.Target
+	}
+}
+
+func newRepository(nodes Nodes, parentHashes func(*Node) []string) *Repository {
+	r := &Repository{
+		Nodes:       make(Nodes, 0, len(nodes)),
+		nodesMap:    make(map[string]*Node),
+		childrenMap: make(map[string]Nodes),
+		parentsMap:  make(map[string]Nodes),
+		waiting:     make(map[string]Nodes),
+	}
+	for _, n := range nodes {
+		r.addNode(n, parentHashes)
+	}
+	return r
+}
+
+func logMissingParents(r *Repository, parentHashes func(*Node) []string) {
+	for _, n := range r.Nodes {
+		for _, parentHash := range parentHashes(n) {
+			if _, ok := r.nodesMap[parentHash]; !ok {
 				log.Printf("node not found: target=%s, parent=%s", n.hash, parentHash)
 			}
 		}
 	}
+}
 
-	return &Repository{
-		Nodes:       nodes,
-		nodesMap:    nodesMap,
-		childrenMap: childrenMap,
-		parentsMap:  parentsMap,
-	}, nil
+// addNode appends n to the graph, and connects it to its parents and children added so far.
+// The children of each node are kept in the order which they are added in, whichever is added first.
+func (r *Repository) addNode(n *Node, parentHashes func(*Node) []string) {
+	n.posY = len(r.Nodes)
+	r.Nodes = append(r.Nodes, n)
+	r.nodesMap[n.hash] = n
+	r.parentsMap[n.hash] = make(Nodes, 0)
+	for _, parentHash := range parentHashes(n) {
+		if parentNode, ok := r.nodesMap[parentHash]; ok {
+			r.parentsMap[n.hash] = append(r.parentsMap[n.hash], parentNode)
+			r.childrenMap[parentHash] = append(r.childrenMap[parentHash], n)
+		} else {
+			r.waiting[parentHash] = append(r.waiting[parentHash], n)
+		}
+	}
+	for _, child := range r.waiting[n.hash] {
+		r.childrenMap[n.hash] = append(r.childrenMap[n.hash], child)
+		// the parents are connected again to keep their order
+		ps := make(Nodes, 0)
+		for _, parentHash := range parentHashes(child) {
+			if parentNode, ok := r.nodesMap[parentHash]; ok {
+				ps = append(ps, parentNode)
+			}
+		}
+		r.parentsMap[child.hash] = ps
+	}
+	delete(r.waiting, n.hash)
 }
 
 type Node struct {
 	Commit *object.Commit
 
-	hash string
-	posY int
-	posX int
+	hash     string
+	posY     int
+	posX     int
+	worktree bool
+}
+
+// newWorktreeNode returns the pseudo node whose only parent is HEAD.
+// Its commit has no tree, so it must not be read as a real commit.
+func newWorktreeNode(head plumbing.Hash) *Node {
+	now := time.Now()
+	return &Node{
+		Commit: &object.Commit{
+			Hash:         plumbing.ZeroHash,
+			Message:      worktreeMessage,
+			Author:       object.Signature{When: now},
+			Committer:    object.Signature{When: now},
+			ParentHashes: []plumbing.Hash{head},
+		},
+		hash:     WorktreeHash,
+		worktree: true,
+	}
 }
 
 func (n *Node) committedAt() time.Time {
@@ -145,18 +605,36 @@ func (n *Node) PosX() int {
 	return n.posX
 }
 
-type Nodes []*Node
-
-func (ns Nodes) hashes() []string {
-	hs := make([]string, len(ns))
-	for i, n := range ns {
-		hs[i] = n.hash
-	}
-	return hs
+func (n *Node) IsWorktree() bool {
+	return n.worktree
 }
 
+type Nodes []*Node
+
 type Option struct {
 	Sort
+
+	// Worktree adds a pseudo node for the uncommitted changes on top of HEAD.
+	Worktree bool
+
+	// From limits the commits to those reachable from the hashes instead of all refs,
+	// and Exclude removes the commits reachable from the hashes. Exclude is ignored if From is empty.
+	From    []plumbing.Hash
+	Exclude []plumbing.Hash
+
+	// FirstParent follows only the first parent of each merge, like `git log --first-parent --all`.
+	FirstParent bool
+
+	// Lazy lays out only the first rows, and the rest as they are requested by EnsureLayout.
+	// If Sort is Chronological and From is empty, the commits are also read as the rows are requested,
+	// so the first rows are shown without reading the whole history.
+	// The other sorts visit the oldest commits first, so all commits are read and sorted before the first row.
+	Lazy bool
+
+	// StreamSource is another handle of the same repository to read the rest of the history through,
+	// since it is read while the handle passed to CalculateContext may be used on another goroutine.
+	// The commits read are still bound to that handle. The handle passed is used if it is nil.
+	StreamSource *git.Repository
 }
 
 type Sort int
@@ -164,17 +642,104 @@ type Sort int
 const (
 	Topological Sort = iota
 	CommitDate
+	// Chronological lists the newest commits first as they are reached from the refs, like `git log --all` without ordering options.
+	// It can be read incrementally, but a commit can be above its child if their commit times are skewed,
+	// and the edge between them is not drawn then.
+	Chronological
 )
 
+type Phase int
+
+const (
+	ReadingCommits Phase = iota
+	LayingOut
+)
+
+// Progress is called with the number of commits read so far.
+type Progress func(phase Phase, commits int)
+
 func Calculate(src *git.Repository, opt *Option) (*Repository, error) {
-	repo, err := initRepository(src)
+	return CalculateContext(context.Background(), src, opt, nil)
+}
+
+// CalculateContext stops reading commits when ctx is canceled. progress may be nil.
+func CalculateContext(ctx context.Context, src *git.Repository, opt *Option, progress Progress) (*Repository, error) {
+	if progress == nil {
+		progress = func(Phase, int) {}
+	}
+
+	if opt.Lazy && opt.Sort == Chronological && len(opt.From) == 0 {
+		return startStream(ctx, src, opt)
+	}
+
+	repo, err := initRepository(ctx, src, opt, progress)
 	if err != nil {
 		return nil, err
 	}
 
+	progress(LayingOut, len(repo.Nodes))
+	layout(repo, opt)
+
+	return repo, ctx.Err()
+}
+
+// Subset returns a new repository which consists only of the nodes of the given hashes.
+// The parents of each node are rewritten to its nearest ancestors in the subset.
+// If the history of r is streamed, it must have been read whole by AllNodes.
+func (r *Repository) Subset(hashes []string, opt *Option) *Repository {
+	included := make(map[string]struct{})
+	for _, h := range hashes {
+		included[h] = struct{}{}
+	}
+
+	// parents are visited before their children in the reverse topological order,
+	// which r.Nodes may not be in if it is in Chronological order
+	sorted := dfsTopologicalSort(r.Nodes, r)
+	nearest := make(map[string][]string)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		n := sorted[i]
+		if _, ok := included[n.hash]; ok {
+			nearest[n.hash] = []string{n.hash}
+			continue
+		}
+		nearest[n.hash] = r.nearestIncludedParents(n, nearest)
+	}
+
+	nodes := make(Nodes, 0, len(hashes))
+	for _, n := range r.Nodes {
+		if _, ok := included[n.hash]; ok {
+			nodes = append(nodes, &Node{Commit: n.Commit, hash: n.hash, worktree: n.worktree})
+		}
+	}
+	sub := newRepository(nodes, func(n *Node) []string {
+		return r.nearestIncludedParents(r.node(n.hash), nearest)
+	})
+	layout(sub, opt)
+	return sub
+}
+
+func (r *Repository) nearestIncludedParents(n *Node, nearest map[string][]string) []string {
+	hs := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, p := range r.parents(n.hash) {
+		for _, h := range nearest[p.hash] {
+			if _, ok := seen[h]; !ok {
+				seen[h] = struct{}{}
+				hs = append(hs, h)
+			}
+		}
+	}
+	return hs
+}
+
+func layout(repo *Repository, opt *Option) {
+	repo.sort = opt.Sort
 	sortNodes(repo, opt)
+	decidePositionsY(repo.Nodes)
+	if opt.Lazy {
+		startLazyLayout(repo)
+		return
+	}
 	calculatePositions(repo)
 	calculateEdges(repo)
-
-	return repo, nil
 }
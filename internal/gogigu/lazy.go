@@ -0,0 +1,148 @@
+package gogigu
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// lazyLayoutRows is the number of rows laid out ahead of the requested row.
+	lazyLayoutRows = 1000
+)
+
+// lazyLayout keeps the state to extend the positions and the edges row by row.
+// EnsureLayout may be called from several goroutines, since the list of fyne updates its rows on the goroutine which refreshes it,
+// so mu guards the whole graph of the repository as well.
+type lazyLayout struct {
+	mu        sync.Mutex
+	positions *positioner
+	// edged is the number of rows whose edges are calculated
+	edged int
+	// open are the nodes above edged which have parents at or below edged
+	open Nodes
+	// stream is nil if all commits have been read before the layout
+	stream *commitStream
+
+	onChange func()
+	// rows and maxPosX are what onChange has been called for
+	rows      int
+	maxPosX   int
+	notifying bool
+}
+
+func startLazyLayout(repo *Repository) {
+	repo.edgesMap = make(map[int]Edges)
+	repo.lazy = &lazyLayout{positions: &positioner{}}
+	repo.EnsureLayout(0)
+}
+
+// EnsureLayout calculates the positions and the edges up to the row of posY if the repository is laid out lazily.
+// The results are the same as when all rows are calculated at once.
+// posX of the nodes up to the row can be read after it returns, since they are never changed once decided.
+func (r *Repository) EnsureLayout(posY int) {
+	l := r.lazy
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if posY < l.edged {
+		return
+	}
+	to := posY + lazyLayoutRows
+	if l.stream != nil {
+		l.stream.readUntil(r, to)
+	}
+	if to > len(r.Nodes) {
+		to = len(r.Nodes)
+	}
+	if l.edged < to {
+		l.extend(r, to)
+	}
+	l.changed(r)
+}
+
+// OnLayoutChanged sets f to be called when rows are read or the graph gets wider by EnsureLayout.
+// f is called on another goroutine, since EnsureLayout is called while the rows are being updated.
+// The changes made before f starts are notified only once.
+func (r *Repository) OnLayoutChanged(f func()) {
+	l := r.lazy
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = f
+	l.rows = len(r.Nodes)
+	l.maxPosX = r.maxPosX
+}
+
+func (l *lazyLayout) changed(repo *Repository) {
+	if l.onChange == nil || l.notifying || (l.rows == len(repo.Nodes) && l.maxPosX == repo.maxPosX) {
+		return
+	}
+	l.notifying = true
+	go func() {
+		l.mu.Lock()
+		f := l.onChange
+		l.rows = len(repo.Nodes)
+		l.maxPosX = repo.maxPosX
+		l.notifying = false
+		l.mu.Unlock()
+		f()
+	}()
+}
+
+func (l *lazyLayout) extend(repo *Repository, to int) {
+	from := l.edged
+	if l.stream != nil {
+		// the edges to the parents need their rows, which may be read far below
+		l.stream.readParents(repo, l.open)
+		l.stream.readParents(repo, repo.Nodes[from:to])
+	}
+
+	// the edges of the rows in [from, to) are drawn only by the nodes connected to the nodes in the rows or across the rows
+	candidates := make(map[*Node]struct{})
+	addWithParents := func(n *Node) {
+		candidates[n] = struct{}{}
+		for _, p := range repo.parents(n.hash) {
+			candidates[p] = struct{}{}
+		}
+	}
+	for _, n := range l.open {
+		addWithParents(n)
+	}
+	for _, n := range repo.Nodes[from:to] {
+		addWithParents(n)
+		for _, c := range repo.children(n.hash) {
+			candidates[c] = struct{}{}
+		}
+	}
+	ns := make(Nodes, 0, len(candidates))
+	for n := range candidates {
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool {
+		return ns[i].posY < ns[j].posY
+	})
+
+	end := ns[len(ns)-1].posY + 1
+	if l.stream != nil && l.positions.next < end {
+		// a node takes over the lane of the children whose first parent it is, which must be known by then
+		l.stream.readParents(repo, repo.Nodes[l.positions.next:end])
+	}
+	l.positions.positionUntil(repo, end)
+	appendEdges(repo, ns, from, to)
+
+	open := make(Nodes, 0)
+	for _, n := range append(l.open, repo.Nodes[from:to]...) {
+		for _, p := range repo.parents(n.hash) {
+			if p.posY >= to {
+				open = append(open, n)
+				break
+			}
+		}
+	}
+	l.open = open
+	l.edged = to
+}
@@ -6,83 +6,66 @@ import (
 )
 
 func calculatePositions(repo *Repository) error {
+	p := &positioner{}
+	p.positionUntil(repo, len(repo.Nodes))
+	return nil
+}
+
+// positioner decides posX of the nodes row by row, so that it can be resumed later.
+type positioner struct {
+	lanes *lanes
+	// next is the row which is positioned next
+	next int
+}
+
+func (p *positioner) positionUntil(repo *Repository, end int) {
 	ns := repo.Nodes
-	activeNodes := make(Nodes, 0)
-	maxPosX := 0
-	for i, n := range ns {
-		childrenHashes := filteredChildrenHashes(n, repo)
-		if len(childrenHashes) > 0 {
-			updated := false
-			for _, childHash := range childrenHashes {
-				if isIn(childHash, activeNodes.hashes()) {
-					activeNodes = updateActiveNodes(n, activeNodes, childHash, childrenHashes)
-					updated = true
-					break
-				}
+	if p.lanes == nil {
+		p.lanes = newLanes(len(ns))
+	}
+	for ; p.next < end; p.next++ {
+		n := ns[p.next]
+		// the node takes over the lane of its first active child, and the lanes of the other children end
+		updated := false
+		for _, childHash := range filteredChildrenHashes(n, repo) {
+			if !p.lanes.contains(childHash) {
+				continue
 			}
-			if !updated {
-				activeNodes = append(activeNodes, n)
+			if updated {
+				p.lanes.remove(childHash)
+			} else {
+				p.lanes.replace(childHash, n.hash)
+				updated = true
 			}
-		} else {
-			activeNodes = append(activeNodes, n)
+		}
+		if !updated {
+			p.lanes.append(n.hash)
 		}
 
-		decidePositionX(n, activeNodes)
-		decidePositionY(n, i)
+		n.posX = p.lanes.index(n.hash)
 
-		if maxPosX < n.posX {
-			maxPosX = n.posX
+		if repo.maxPosX < n.posX {
+			repo.maxPosX = n.posX
 		}
 	}
-	repo.maxPosX = maxPosX
-	return nil
 }
 
+// filteredChildrenHashes returns the hashes of the children whose first parent is n.
 func filteredChildrenHashes(n *Node, repo *Repository) []string {
 	hs := make([]string, 0)
-	childrenHashes := repo.ChildrenHashes(n.hash)
-	for _, h := range childrenHashes {
-		childParentsHashes := repo.ParentsHashes(h)
-		if len(childParentsHashes) > 0 && childParentsHashes[0] == n.hash {
-			hs = append(hs, h)
+	for _, child := range repo.children(n.hash) {
+		childParents := repo.parents(child.hash)
+		if len(childParents) > 0 && childParents[0] == n {
+			hs = append(hs, child.hash)
 		}
 	}
 	return hs
 }
 
-func isIn(targetHash string, hashes []string) bool {
-	for _, hash := range hashes {
-		if hash == targetHash {
-			return true
-		}
-	}
-	return false
-}
-
-func updateActiveNodes(targetNode *Node, activeNodes Nodes, targetChildHash string, childrenHashes []string) Nodes {
-	newActiveNodes := Nodes{}
-	for _, n := range activeNodes {
-		if n.hash == targetChildHash {
-			newActiveNodes = append(newActiveNodes, targetNode)
-		} else if !isIn(n.hash, childrenHashes) {
-			newActiveNodes = append(newActiveNodes, n)
-		}
-	}
-	return newActiveNodes
-}
-
-func decidePositionX(target *Node, activeNodes Nodes) {
-	for i, n := range activeNodes {
-		if target == n {
-			target.posX = i
-			return
-		}
+func decidePositionsY(ns Nodes) {
+	for i, n := range ns {
+		n.posY = i
 	}
-	panic(fmt.Errorf("node not found [target = %v, activeNodes = %v]", target.debugString(), activeNodes.debugString()))
-}
-
-func decidePositionY(target *Node, i int) {
-	target.posY = i
 }
 
 func (n *Node) debugString() string {
@@ -0,0 +1,205 @@
+package gogigu
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// scanPositions decides posX like the implementation which the lanes replaced,
+// which scans the active nodes for every child of every node.
+func scanPositions(repo *Repository) {
+	activeNodes := Nodes{}
+	isIn := func(target string, hashes []string) bool {
+		for _, h := range hashes {
+			if h == target {
+				return true
+			}
+		}
+		return false
+	}
+	for _, n := range repo.Nodes {
+		childrenHashes := make([]string, 0)
+		for _, h := range repo.ChildrenHashes(n.hash) {
+			ps := repo.ParentsHashes(h)
+			if len(ps) > 0 && ps[0] == n.hash {
+				childrenHashes = append(childrenHashes, h)
+			}
+		}
+		updated := false
+		for _, childHash := range childrenHashes {
+			activeHashes := make([]string, len(activeNodes))
+			for i, a := range activeNodes {
+				activeHashes[i] = a.hash
+			}
+			if !isIn(childHash, activeHashes) {
+				continue
+			}
+			newActiveNodes := Nodes{}
+			for _, a := range activeNodes {
+				if a.hash == childHash {
+					newActiveNodes = append(newActiveNodes, n)
+				} else if !isIn(a.hash, childrenHashes) {
+					newActiveNodes = append(newActiveNodes, a)
+				}
+			}
+			activeNodes = newActiveNodes
+			updated = true
+			break
+		}
+		if !updated {
+			activeNodes = append(activeNodes, n)
+		}
+		for i, a := range activeNodes {
+			if a == n {
+				n.posX = i
+			}
+		}
+	}
+}
+
+func TestCalculatePositionsMatchScan(t *testing.T) {
+	dags := []dag{
+		{commits: 1000, branches: 1},
+		{commits: 3000, branches: 10, mergeRate: 0.2},
+		{commits: 3000, branches: 100, mergeRate: 0.4, skewRate: 0.05},
+		{commits: 5000, branches: 300, mergeRate: 0.1},
+	}
+	for _, d := range dags {
+		for _, s := range []Sort{CommitDate, Topological} {
+			for seed := int64(0); seed < 5; seed++ {
+				repo := generateRepository(d, seed)
+				sortNodes(repo, &Option{Sort: s})
+				decidePositionsY(repo.Nodes)
+
+				calculatePositions(repo)
+				calculateEdges(repo)
+				posX := make([]int, len(repo.Nodes))
+				for i, n := range repo.Nodes {
+					posX[i] = n.posX
+				}
+				edges := repo.edgesMap
+
+				scanPositions(repo)
+				calculateEdges(repo)
+				for i, n := range repo.Nodes {
+					if posX[i] != n.posX {
+						t.Fatalf("%+v seed %d: posX of row %d = %d, want %d", d, seed, i, posX[i], n.posX)
+					}
+					got, want := edges[i], repo.edgesMap[i]
+					if len(got) != len(want) {
+						t.Fatalf("%+v seed %d: %d edges in row %d, want %d", d, seed, len(got), i, len(want))
+					}
+					for j := range got {
+						if *got[j] != *want[j] {
+							t.Fatalf("%+v seed %d: edge %d of row %d = %+v, want %+v", d, seed, j, i, *got[j], *want[j])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkCalculatePositions(b *testing.B) {
+	dags := []struct {
+		name string
+		dag
+	}{
+		{"deep", dag{commits: 1000000, branches: 3, mergeRate: 0.05}},
+		{"wide", dag{commits: 100000, branches: 500, mergeRate: 0.05}},
+	}
+	for _, d := range dags {
+		repo := generateRepository(d.dag, 0)
+		// the topological order keeps the number of the lanes close to the number of the branches
+		sortNodes(repo, &Option{Sort: Topological})
+		decidePositionsY(repo.Nodes)
+		calculatePositions(repo)
+		name := fmt.Sprintf("%s/%d commits/%d lanes", d.name, d.commits, repo.maxPosX+1)
+		b.Run(name+"/lanes", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				calculatePositions(repo)
+			}
+		})
+		b.Run(name+"/scan", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanPositions(repo)
+			}
+		})
+	}
+}
+
+// assertSameRows compares the rows in [from, to) of got with those of want, which is laid out at once.
+func assertSameRows(t *testing.T, got, want *Repository, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		g, w := got.Nodes[i], want.Nodes[i]
+		if g.hash != w.hash {
+			t.Fatalf("nodes[%d] = %s, want %s", i, g.ShortHash(), w.ShortHash())
+		}
+		if g.posX != w.posX {
+			t.Fatalf("posX of row %d = %d, want %d", i, g.posX, w.posX)
+		}
+		ge, we := got.edgesMap[i], want.edgesMap[i]
+		if len(ge) != len(we) {
+			t.Fatalf("%d edges in row %d, want %d", len(ge), i, len(we))
+		}
+		for j := range ge {
+			if *ge[j] != *we[j] {
+				t.Fatalf("edge %d of row %d = %+v, want %+v", j, i, *ge[j], *we[j])
+			}
+		}
+	}
+}
+
+// ensureLayoutInSteps requests the rows at random steps like scrolling down to the last row,
+// checking the rows laid out by each step against want.
+func ensureLayoutInSteps(t *testing.T, repo, want *Repository, seed int64) {
+	t.Helper()
+	r := rand.New(rand.NewSource(seed))
+	edged := 0
+	for posY := 0; ; posY += 1 + r.Intn(2*lazyLayoutRows) {
+		if last := repo.Len() - 1; posY > last {
+			posY = last
+		}
+		repo.EnsureLayout(posY)
+		if repo.lazy.edged <= posY {
+			t.Fatalf("row %d is not laid out: %d rows laid out", posY, repo.lazy.edged)
+		}
+		if repo.Len() > len(want.Nodes) {
+			t.Fatalf("len = %d, want %d", repo.Len(), len(want.Nodes))
+		}
+		assertSameRows(t, repo, want, edged, repo.lazy.edged)
+		edged = repo.lazy.edged
+		// the rows are read ahead of the row requested unless all are read
+		if posY == repo.Len()-1 {
+			break
+		}
+	}
+	if len(repo.Nodes) != len(want.Nodes) || edged != len(want.Nodes) {
+		t.Fatalf("%d rows laid out of %d, want %d", edged, len(repo.Nodes), len(want.Nodes))
+	}
+	if repo.maxPosX != want.maxPosX {
+		t.Fatalf("maxPosX = %d, want %d", repo.maxPosX, want.maxPosX)
+	}
+}
+
+func TestEnsureLayoutMatchesFull(t *testing.T) {
+	dags := []dag{
+		{commits: 1000, branches: 1},
+		{commits: 3000, branches: 10, mergeRate: 0.2},
+		{commits: 5000, branches: 100, mergeRate: 0.4, skewRate: 0.05},
+		{commits: 5000, branches: 300, mergeRate: 0.1},
+	}
+	for _, d := range dags {
+		for _, s := range []Sort{CommitDate, Topological} {
+			for seed := int64(0); seed < 3; seed++ {
+				want := generateRepository(d, seed)
+				layout(want, &Option{Sort: s})
+				repo := generateRepository(d, seed)
+				layout(repo, &Option{Sort: s, Lazy: true})
+				ensureLayoutInSteps(t, repo, want, seed)
+			}
+		}
+	}
+}
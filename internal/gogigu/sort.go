@@ -3,6 +3,11 @@ package gogigu
 import "sort"
 
 func sortNodes(repo *Repository, opt *Option) {
+	if opt.Sort == Chronological {
+		// the nodes are already in the order read
+		moveWorktreeNodeToTop(repo.Nodes)
+		return
+	}
 	ns := repo.Nodes
 	sort.Slice(ns, func(i, j int) bool {
 		return ns[i].committedAt().Before(ns[j].committedAt())
@@ -13,50 +18,82 @@ func sortNodes(repo *Repository, opt *Option) {
 	case CommitDate:
 		repo.Nodes = bfsTopologicalSort(ns, repo)
 	}
+	moveWorktreeNodeToTop(repo.Nodes)
+}
+
+// moveWorktreeNodeToTop keeps the order topological since the worktree node has no children.
+func moveWorktreeNodeToTop(ns Nodes) {
+	for i, n := range ns {
+		if n.worktree {
+			copy(ns[1:i+1], ns[:i])
+			ns[0] = n
+			return
+		}
+	}
 }
 
+// bfsTopologicalSort appends each node after the nodes reached from its children, visiting older nodes first.
+// It keeps the frames of the recursive visits on a stack instead of the call stack, since histories can be very deep.
 func bfsTopologicalSort(ns Nodes, repo *Repository) Nodes {
 	sorted := make([]*Node, 0, len(ns))
 	visited := make(map[string]struct{})
 	q := NewQueue()
-	var bfs func(n *Node)
-	bfs = func(n *Node) {
+	stack := make(Nodes, 0)
+	visit := func(n *Node) {
 		if _, ok := visited[n.hash]; ok {
 			return
 		}
 		visited[n.hash] = struct{}{}
-		children := repo.Children(n.hash)
-		for _, child := range children {
+		for _, child := range repo.children(n.hash) {
 			q.Enqueue(child)
 		}
-		for len(*q) > 0 {
-			bfs(q.Dequeue())
-		}
-		sorted = append(sorted, n)
+		stack = append(stack, n)
 	}
 	for _, n := range ns {
-		bfs(n)
+		visit(n)
+		for len(stack) > 0 {
+			if len(*q) > 0 {
+				visit(q.Dequeue())
+				continue
+			}
+			top := len(stack) - 1
+			sorted = append(sorted, stack[top])
+			stack = stack[:top]
+		}
 	}
 	return sorted
 }
 
+// dfsTopologicalSort appends each node after all its descendants, visiting the children in order.
 func dfsTopologicalSort(ns Nodes, repo *Repository) Nodes {
+	type frame struct {
+		node *Node
+		// next is the index of the child to be visited next
+		next int
+	}
 	sorted := make([]*Node, 0, len(ns))
 	visited := make(map[string]struct{})
-	var dfs func(n *Node)
-	dfs = func(n *Node) {
+	stack := make([]*frame, 0)
+	visit := func(n *Node) {
 		if _, ok := visited[n.hash]; ok {
 			return
 		}
 		visited[n.hash] = struct{}{}
-		children := repo.Children(n.hash)
-		for _, child := range children {
-			dfs(child)
-		}
-		sorted = append(sorted, n)
+		stack = append(stack, &frame{node: n})
 	}
 	for _, n := range ns {
-		dfs(n)
+		visit(n)
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			children := repo.children(f.node.hash)
+			if f.next < len(children) {
+				f.next++
+				visit(children[f.next-1])
+				continue
+			}
+			sorted = append(sorted, f.node)
+			stack = stack[:len(stack)-1]
+		}
 	}
 	return sorted
 }
@@ -0,0 +1,203 @@
+package gogigu
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// dag describes a synthetic history. The commits are created from the oldest.
+type dag struct {
+	commits int
+	// branches is the max number of the branches growing at the same time
+	branches int
+	// mergeRate is the probability that a commit merges another branch
+	mergeRate float64
+	// skewRate is the probability that a commit is older than its parents, like with a wrong clock
+	skewRate float64
+}
+
+func testHash(i int) plumbing.Hash {
+	var h plumbing.Hash
+	binary.BigEndian.PutUint64(h[len(h)-8:], uint64(i+1))
+	return h
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// testCommit is a commit of a synthetic history, whose parents are the indexes of the older commits.
+type testCommit struct {
+	parents []int
+	when    time.Time
+}
+
+// generateCommits returns a random history of d from the oldest, and the indexes of the commits of the branch tips.
+func generateCommits(d dag, r *rand.Rand) ([]testCommit, []int) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commits := make([]testCommit, 0, d.commits)
+	tips := make([]int, 0, d.branches)
+	for i := 0; i < d.commits; i++ {
+		parents := make([]int, 0, 2)
+		switch {
+		case len(tips) == 0 || (len(tips) < d.branches && r.Intn(4) == 0):
+			// a new branch from one of the recent commits, or the root
+			if i > 0 {
+				parents = append(parents, i-1-r.Intn(minInt(i, 100)))
+			}
+			tips = append(tips, i)
+		default:
+			t := r.Intn(len(tips))
+			parents = append(parents, tips[t])
+			if len(tips) > 1 && r.Float64() < d.mergeRate {
+				m := r.Intn(len(tips))
+				if m != t {
+					parents = append(parents, tips[m])
+					tips = append(tips[:m], tips[m+1:]...)
+					if m < t {
+						t--
+					}
+				}
+			}
+			tips[t] = i
+		}
+		// some commits share the same time, since the times are in seconds
+		when := base.Add(time.Duration(i/2) * time.Second)
+		if r.Float64() < d.skewRate {
+			when = when.Add(-time.Duration(r.Intn(1000)) * time.Second)
+		}
+		commits = append(commits, testCommit{parents: parents, when: when})
+	}
+	return commits, tips
+}
+
+// generateRepository returns the repository of a random history of d, which is not laid out yet.
+func generateRepository(d dag, seed int64) *Repository {
+	r := rand.New(rand.NewSource(seed))
+	commits, _ := generateCommits(d, r)
+	nodes := make(Nodes, 0, len(commits))
+	for i, c := range commits {
+		parents := make([]plumbing.Hash, len(c.parents))
+		for j, p := range c.parents {
+			parents[j] = testHash(p)
+		}
+		h := testHash(i)
+		nodes = append(nodes, &Node{
+			Commit: &object.Commit{
+				Hash:         h,
+				Committer:    object.Signature{When: c.when},
+				ParentHashes: parents,
+			},
+			hash: h.String(),
+		})
+	}
+	r.Shuffle(len(nodes), func(i, j int) {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	})
+	return newRepository(nodes, func(n *Node) []string {
+		hs := make([]string, len(n.Commit.ParentHashes))
+		for i, h := range n.Commit.ParentHashes {
+			hs[i] = h.String()
+		}
+		return hs
+	})
+}
+
+// recursiveBfsTopologicalSort is the recursive implementation which bfsTopologicalSort replaced.
+func recursiveBfsTopologicalSort(ns Nodes, repo *Repository) Nodes {
+	sorted := make([]*Node, 0, len(ns))
+	visited := make(map[string]struct{})
+	q := NewQueue()
+	var bfs func(n *Node)
+	bfs = func(n *Node) {
+		if _, ok := visited[n.hash]; ok {
+			return
+		}
+		visited[n.hash] = struct{}{}
+		children := repo.Children(n.hash)
+		for _, child := range children {
+			q.Enqueue(child)
+		}
+		for len(*q) > 0 {
+			bfs(q.Dequeue())
+		}
+		sorted = append(sorted, n)
+	}
+	for _, n := range ns {
+		bfs(n)
+	}
+	return sorted
+}
+
+// recursiveDfsTopologicalSort is the recursive implementation which dfsTopologicalSort replaced.
+func recursiveDfsTopologicalSort(ns Nodes, repo *Repository) Nodes {
+	sorted := make([]*Node, 0, len(ns))
+	visited := make(map[string]struct{})
+	var dfs func(n *Node)
+	dfs = func(n *Node) {
+		if _, ok := visited[n.hash]; ok {
+			return
+		}
+		visited[n.hash] = struct{}{}
+		children := repo.Children(n.hash)
+		for _, child := range children {
+			dfs(child)
+		}
+		sorted = append(sorted, n)
+	}
+	for _, n := range ns {
+		dfs(n)
+	}
+	return sorted
+}
+
+func assertSameOrder(t *testing.T, got, want Nodes) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("nodes[%d] = %s, want %s", i, got[i].ShortHash(), want[i].ShortHash())
+		}
+	}
+}
+
+func TestTopologicalSortsMatchRecursive(t *testing.T) {
+	dags := []dag{
+		{commits: 1000, branches: 1},
+		{commits: 2000, branches: 5, mergeRate: 0.1},
+		{commits: 2000, branches: 50, mergeRate: 0.3, skewRate: 0.05},
+		{commits: 5000, branches: 200, mergeRate: 0.5, skewRate: 0.2},
+	}
+	for _, d := range dags {
+		for seed := int64(0); seed < 10; seed++ {
+			repo := generateRepository(d, seed)
+			ns := repo.Nodes
+			sortNodes(repo, &Option{Sort: CommitDate})
+			// sortNodes has sorted ns by the commit time in place, which the sorts receive
+			assertSameOrder(t, bfsTopologicalSort(ns, repo), recursiveBfsTopologicalSort(ns, repo))
+			assertSameOrder(t, dfsTopologicalSort(ns, repo), recursiveDfsTopologicalSort(ns, repo))
+		}
+	}
+}
+
+func TestTopologicalSortsMatchRecursiveMillion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping a million commits in short mode")
+	}
+	// the recursive sorts go as deep as the longest chain of children, which the default max stack allows
+	repo := generateRepository(dag{commits: 1000000, branches: 20, mergeRate: 0.2, skewRate: 0.01}, 0)
+	ns := repo.Nodes
+	sortNodes(repo, &Option{Sort: CommitDate})
+	assertSameOrder(t, bfsTopologicalSort(ns, repo), recursiveBfsTopologicalSort(ns, repo))
+	assertSameOrder(t, dfsTopologicalSort(ns, repo), recursiveDfsTopologicalSort(ns, repo))
+}
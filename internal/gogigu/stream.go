@@ -0,0 +1,252 @@
+package gogigu
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"log"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	// streamChunk is the number of commits read at once when the rest of the history is requested.
+	streamChunk = 1000
+)
+
+// commitWalker reads the commits reachable from the tips from the newest, like `git log` without ordering options.
+// A commit is read after at least one of its children, but may be read before another child whose commit time is older.
+type commitWalker struct {
+	src *git.Repository
+	// bind is the storage which the commits read use to read their trees and parents
+	bind        storer.EncodedObjectStorer
+	firstParent bool
+
+	queue  *walkQueue
+	queued map[plumbing.Hash]struct{}
+	seen   map[plumbing.Hash]struct{}
+	pushed int
+}
+
+// newCommitWalker does not read the commits in seen, which may be reachable from the tips.
+func newCommitWalker(src *git.Repository, bind storer.EncodedObjectStorer, tips []plumbing.Hash, seen map[plumbing.Hash]struct{}, firstParent bool) (*commitWalker, error) {
+	w := &commitWalker{
+		src:         src,
+		bind:        bind,
+		firstParent: firstParent,
+		queue:       &walkQueue{},
+		queued:      make(map[plumbing.Hash]struct{}),
+		seen:        seen,
+	}
+	for _, h := range tips {
+		if err := w.push(h); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *commitWalker) push(h plumbing.Hash) error {
+	if _, ok := w.seen[h]; ok {
+		return nil
+	}
+	w.seen[h] = struct{}{}
+	c, err := readCommit(w.src.Storer, w.bind, h)
+	if err != nil || c == nil {
+		return err
+	}
+	heap.Push(w.queue, &walkItem{commit: c, seq: w.pushed})
+	w.pushed++
+	w.queued[h] = struct{}{}
+	return nil
+}
+
+// next returns io.EOF after all commits are read.
+func (w *commitWalker) next() (*object.Commit, error) {
+	if w.queue.Len() == 0 {
+		return nil, io.EOF
+	}
+	c := heap.Pop(w.queue).(*walkItem).commit
+	delete(w.queued, c.Hash)
+	ps := c.ParentHashes
+	if w.firstParent && len(ps) > 1 {
+		ps = ps[:1]
+	}
+	for _, p := range ps {
+		if err := w.push(p); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// pending reports whether the commit will be read later.
+func (w *commitWalker) pending(h plumbing.Hash) bool {
+	_, ok := w.queued[h]
+	return ok
+}
+
+type walkItem struct {
+	commit *object.Commit
+	// seq keeps the order of the commits of the same time
+	seq int
+}
+
+// walkQueue pops the newest commit first.
+type walkQueue []*walkItem
+
+func (q walkQueue) Len() int {
+	return len(q)
+}
+
+func (q walkQueue) Less(i, j int) bool {
+	ti, tj := q[i].commit.Committer.When, q[j].commit.Committer.When
+	if ti.Equal(tj) {
+		return q[i].seq < q[j].seq
+	}
+	return ti.After(tj)
+}
+
+func (q walkQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *walkQueue) Push(x interface{}) {
+	*q = append(*q, x.(*walkItem))
+}
+
+func (q *walkQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*q = old[0 : n-1]
+	return x
+}
+
+// commitStream reads the rest of the history of a repository as its rows are requested.
+type commitStream struct {
+	walker       *commitWalker
+	parentHashes func(*Node) []string
+	// err stops the stream, and is returned by AllNodes
+	err error
+}
+
+// startStream shows the first rows of the history of all refs in Chronological order, reading only the commits of them.
+// The rest is read through opt.StreamSource as EnsureLayout requests more rows.
+func startStream(ctx context.Context, src *git.Repository, opt *Option) (*Repository, error) {
+	reader := opt.StreamSource
+	if reader == nil {
+		reader = src
+	}
+	tips, err := refTips(src)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newCommitWalker(reader, src.Storer, tips, make(map[plumbing.Hash]struct{}), opt.FirstParent)
+	if err != nil {
+		return nil, err
+	}
+	parentHashes := graphParentHashes(opt)
+	repo := newRepository(Nodes{}, parentHashes)
+	if opt.Worktree {
+		head, err := src.Head()
+		if err == nil {
+			repo.addNode(newWorktreeNode(head.Hash()), parentHashes)
+		} else if err != plumbing.ErrReferenceNotFound {
+			return nil, err
+		}
+	}
+	repo.sort = Chronological
+	repo.edgesMap = make(map[int]Edges)
+	repo.lazy = &lazyLayout{
+		positions: &positioner{},
+		stream:    &commitStream{walker: w, parentHashes: parentHashes},
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.EnsureLayout(0)
+	return repo, repo.lazy.stream.err
+}
+
+// read reads up to count commits, and reports whether more commits may be read.
+func (s *commitStream) read(repo *Repository, count int) bool {
+	if s.err != nil {
+		return false
+	}
+	for i := 0; i < count; i++ {
+		c, err := s.walker.next()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			log.Printf("failed to read commits: %v", err)
+			s.err = err
+			return false
+		}
+		repo.addNode(&Node{Commit: c, hash: c.Hash.String()}, s.parentHashes)
+	}
+	return true
+}
+
+// readUntil reads the commits until the repository has n rows.
+func (s *commitStream) readUntil(repo *Repository, n int) {
+	for len(repo.Nodes) < n && s.read(repo, n-len(repo.Nodes)) {
+	}
+}
+
+// readParents reads the commits until all the parents of ns are read, so that their edges can be laid out.
+func (s *commitStream) readParents(repo *Repository, ns Nodes) {
+	for _, n := range ns {
+		for _, h := range s.parentHashes(n) {
+			for repo.node(h) == nil && s.walker.pending(plumbing.NewHash(h)) && s.read(repo, 1) {
+			}
+		}
+	}
+}
+
+// AllNodes returns all the nodes, reading the rest of the history first if it is streamed.
+// The nodes and their parents and children do not change once the whole history is read, so they can be read without locks.
+func (r *Repository) AllNodes() (Nodes, error) {
+	l := r.lazy
+	if l == nil {
+		return r.Nodes, nil
+	}
+	for {
+		// the lock is released between the chunks, so that the rows can be drawn meanwhile
+		l.mu.Lock()
+		more := l.stream != nil && l.stream.read(r, streamChunk)
+		ns := r.Nodes
+		var err error
+		if l.stream != nil {
+			err = l.stream.err
+		}
+		l.changed(r)
+		l.mu.Unlock()
+		if !more {
+			return ns, err
+		}
+	}
+}
+
+// Lookup is like Node, but reads the history further until the commit is found if it is streamed.
+func (r *Repository) Lookup(hash string) *Node {
+	l := r.lazy
+	if l == nil {
+		return r.node(hash)
+	}
+	for {
+		l.mu.Lock()
+		n := r.node(hash)
+		more := n == nil && l.stream != nil && l.stream.read(r, streamChunk)
+		l.changed(r)
+		l.mu.Unlock()
+		if !more {
+			return n
+		}
+	}
+}
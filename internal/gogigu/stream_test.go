@@ -0,0 +1,116 @@
+package gogigu
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// generateSource returns a repository in memory of a random history of d, with a branch at each tip.
+func generateSource(t *testing.T, d dag, seed int64) *git.Repository {
+	t.Helper()
+	st := memory.NewStorage()
+	commits, tips := generateCommits(d, rand.New(rand.NewSource(seed)))
+	hashes := make([]plumbing.Hash, len(commits))
+	for i, c := range commits {
+		parents := make([]plumbing.Hash, len(c.parents))
+		for j, p := range c.parents {
+			parents[j] = hashes[p]
+		}
+		sig := object.Signature{Name: "test", When: c.when}
+		commit := &object.Commit{
+			Author:       sig,
+			Committer:    sig,
+			Message:      fmt.Sprintf("commit %d", i),
+			ParentHashes: parents,
+		}
+		obj := st.NewEncodedObject()
+		if err := commit.Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+		h, err := st.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = h
+	}
+	for i, tip := range tips {
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(fmt.Sprintf("branch-%d", i)), hashes[tip])
+		if err := st.SetReference(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("branch-0"))
+	if err := st.SetReference(head); err != nil {
+		t.Fatal(err)
+	}
+	src, err := git.Open(st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestStreamMatchesFull(t *testing.T) {
+	dags := []dag{
+		{commits: 1000, branches: 1},
+		{commits: 5000, branches: 10, mergeRate: 0.2},
+		{commits: 5000, branches: 100, mergeRate: 0.4, skewRate: 0.05},
+		{commits: 5000, branches: 300, mergeRate: 0.1, skewRate: 0.2},
+	}
+	ctx := context.Background()
+	for _, d := range dags {
+		for _, firstParent := range []bool{false, true} {
+			for seed := int64(0); seed < 3; seed++ {
+				src := generateSource(t, d, seed)
+				want, err := CalculateContext(ctx, src, &Option{Sort: Chronological, FirstParent: firstParent}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				repo, err := CalculateContext(ctx, src, &Option{Sort: Chronological, FirstParent: firstParent, Lazy: true}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(want.Nodes) > 2*lazyLayoutRows && repo.Len() >= len(want.Nodes) {
+					t.Fatalf("%+v: all %d commits are read before the first row", d, repo.Len())
+				}
+				ensureLayoutInSteps(t, repo, want, seed)
+				ns, err := repo.AllNodes()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ns) != len(want.Nodes) {
+					t.Fatalf("%+v: %d nodes, want %d", d, len(ns), len(want.Nodes))
+				}
+			}
+		}
+	}
+}
+
+func TestChronologicalOrder(t *testing.T) {
+	src := generateSource(t, dag{commits: 3000, branches: 50, mergeRate: 0.3}, 0)
+	repo, err := Calculate(src, &Option{Sort: Chronological})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Nodes) != 3000 {
+		t.Fatalf("len = %d, want %d", len(repo.Nodes), 3000)
+	}
+	// without skews, the newest commits are listed first and every commit is below its newer children
+	for i, n := range repo.Nodes {
+		if i > 0 && n.committedAt().After(repo.Nodes[i-1].committedAt()) {
+			t.Fatalf("nodes[%d] is newer than nodes[%d]", i, i-1)
+		}
+		for _, p := range repo.Parents(n.hash) {
+			if p.posY < n.posY && p.committedAt().Before(n.committedAt()) {
+				t.Fatalf("nodes[%d] is below its older parent nodes[%d]", n.posY, p.posY)
+			}
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"github.com/lusingander/fynegit/internal/gogigu"
-	"github.com/lusingander/fynegit/internal/repository"
 )
 
 const (
@@ -15,12 +14,14 @@ const (
 	graphCircleRadius = 5
 )
 
-func CalcCommitGraphAreaWidth(rm *repository.RepositoryManager) float32 {
-	return float32((rm.MaxPosX() + 1) * graphWidthUnit)
+func CalcCommitGraphAreaWidth(repo *gogigu.Repository) float32 {
+	return float32((repo.MaxPosX() + 1) * graphWidthUnit)
 }
 
-func CalcCommitGraphTreeRow(rm *repository.RepositoryManager, node *gogigu.Node, height float32) fyne.CanvasObject {
-	graphAreaWidth := CalcCommitGraphAreaWidth(rm)
+func CalcCommitGraphTreeRow(repo *gogigu.Repository, node *gogigu.Node, height float32) fyne.CanvasObject {
+	repo.EnsureLayout(node.PosY())
+
+	graphAreaWidth := CalcCommitGraphAreaWidth(repo)
 	graphAreaHeight := height
 
 	posX := float32((node.PosX()+1)*graphWidthUnit) - (graphWidthUnit / 2)
@@ -29,7 +30,7 @@ func CalcCommitGraphTreeRow(rm *repository.RepositoryManager, node *gogigu.Node,
 
 	objs := make([]fyne.CanvasObject, 0)
 
-	for _, edge := range rm.Edges(node.PosY()) {
+	for _, edge := range repo.Edges(node.PosY()) {
 		e := createCommitTreeEdge(node, edge, graphAreaWidth, graphAreaHeight, posX, posY, circleRadius)
 		objs = append(objs, e)
 	}
@@ -109,12 +110,15 @@ func createDummyBackgroundRectangle(width, height float32) fyne.CanvasObject {
 }
 
 func createCommitObjectCircle(node *gogigu.Node, posX, posY, circleRadius float32) fyne.CanvasObject {
-	color := getColor(node.PosX())
+	c := getColor(node.PosX())
 	circle := &canvas.Circle{
-		StrokeColor: color,
-		FillColor:   color,
+		StrokeColor: c,
+		FillColor:   c,
 		StrokeWidth: 2,
 	}
+	if node.IsWorktree() {
+		circle.FillColor = color.Transparent
+	}
 	circle.Move(fyne.NewPos(posX-circleRadius, posY-circleRadius))
 	circle.Resize(fyne.NewSize(circleRadius*2, circleRadius*2))
 	return circle
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+type BlameLine struct {
+	commit  *object.Commit
+	lineNo  int
+	content string
+}
+
+// Hash returns the hash of the commit which last changed the line.
+// The commit may be out of the graph if it shows only the first parents or some revisions.
+func (l *BlameLine) Hash() string {
+	return l.commit.Hash.String()
+}
+
+func (l *BlameLine) ShortHash() string {
+	return l.Hash()[:7]
+}
+
+func (l *BlameLine) Author() string {
+	return l.commit.Author.Name
+}
+
+func (l *BlameLine) AuthorEmail() string {
+	return l.commit.Author.Email
+}
+
+func (l *BlameLine) When() time.Time {
+	return l.commit.Author.When
+}
+
+func (l *BlameLine) LineNo() int {
+	return l.lineNo
+}
+
+func (l *BlameLine) Content() string {
+	return l.content
+}
+
+// blameTarget holds the lines of the file at a commit which are not blamed yet.
+// lines maps the line index in the file at the commit to the line indices in the blamed file.
+type blameTarget struct {
+	path  string
+	lines map[int][]int
+}
+
+// blameWalk passes the lines from the commits to their parents.
+type blameWalk struct {
+	commit  commitLookup
+	pending map[plumbing.Hash]*blameTarget
+	queue   *commitQueue
+}
+
+// Blame returns, for each line of the file at the target commit, the commit which last changed it.
+// Lines are passed to the parents from which they are unchanged, following renames,
+// and the remaining lines are blamed on the commit.
+// The real parents of the commits are followed even if the graph shows only the first parents or some revisions.
+func (m *RepositoryManager) Blame(target *gogigu.Node, path string) ([]*BlameLine, error) {
+	content, binary, err := m.FileContent(target, path)
+	if err != nil {
+		return nil, err
+	}
+	if binary {
+		return []*BlameLine{}, nil
+	}
+	contents := splitLines(content)
+	result := make([]*BlameLine, len(contents))
+	for i, c := range contents {
+		result[i] = &BlameLine{lineNo: i + 1, content: c}
+	}
+
+	lines := make(map[int][]int)
+	for i := range contents {
+		lines[i] = []int{i}
+	}
+	w := &blameWalk{
+		commit:  graphCommits(m.src, m.Repository),
+		pending: make(map[plumbing.Hash]*blameTarget),
+		queue:   &commitQueue{},
+	}
+	w.add(target.Commit, path, lines)
+	remaining := len(contents)
+
+	// The newest commits are processed first, so the lines passed from the children are usually processed together.
+	// A commit which gets lines after it is processed, because of skewed commit times, is processed again for them.
+	for remaining > 0 && w.queue.Len() > 0 {
+		c := heap.Pop(w.queue).(*object.Commit)
+		bt := w.pending[c.Hash]
+		delete(w.pending, c.Hash)
+		passed, err := m.passBlameToParents(w, c, bt)
+		if err != nil {
+			return nil, err
+		}
+		for i, origs := range bt.lines {
+			if _, ok := passed[i]; ok {
+				continue
+			}
+			for _, orig := range origs {
+				result[orig].commit = c
+				remaining--
+			}
+		}
+	}
+	for _, l := range result {
+		if l.commit == nil {
+			l.commit = target.Commit
+		}
+	}
+	return result, nil
+}
+
+// passBlameToParents returns the line indices passed to any parent.
+func (m *RepositoryManager) passBlameToParents(w *blameWalk, c *object.Commit, bt *blameTarget) (map[int]struct{}, error) {
+	passed := make(map[int]struct{})
+	ps, err := parentCommits(w.commit, c)
+	if err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return passed, nil
+	}
+	h, err := fileHash(c, bt.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ps {
+		ph, err := fileHash(p, bt.path)
+		if err != nil {
+			return nil, err
+		}
+		if ph == h {
+			// unchanged from this parent, so all lines come from it
+			for i := range bt.lines {
+				passed[i] = struct{}{}
+			}
+			w.add(p, bt.path, bt.lines)
+			return passed, nil
+		}
+	}
+	content, _, err := commitFileContent(c, bt.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ps {
+		parentPath := bt.path
+		ph, err := fileHash(p, parentPath)
+		if err != nil {
+			return nil, err
+		}
+		if ph.IsZero() {
+			parentPath, err = m.renamedFrom(p, c, bt.path)
+			if err != nil {
+				return nil, err
+			}
+			if parentPath == "" {
+				continue
+			}
+		}
+		parentContent, binary, err := commitFileContent(p, parentPath)
+		if err != nil {
+			return nil, err
+		}
+		if binary {
+			continue
+		}
+		lines := make(map[int][]int)
+		for _, l := range diffLines(parentContent, content) {
+			if l.lineType != DiffContext {
+				continue
+			}
+			i := l.newLineNo - 1
+			if _, ok := passed[i]; ok {
+				continue
+			}
+			if origs, ok := bt.lines[i]; ok {
+				lines[l.oldLineNo-1] = origs
+				passed[i] = struct{}{}
+			}
+		}
+		if len(lines) > 0 {
+			w.add(p, parentPath, lines)
+		}
+	}
+	return passed, nil
+}
+
+// add queues the commit with the lines, or adds the lines if the commit is queued already.
+func (w *blameWalk) add(c *object.Commit, path string, lines map[int][]int) {
+	bt, ok := w.pending[c.Hash]
+	if !ok {
+		w.pending[c.Hash] = &blameTarget{path: path, lines: lines}
+		heap.Push(w.queue, c)
+		return
+	}
+	for i, origs := range lines {
+		bt.lines[i] = append(bt.lines[i], origs...)
+	}
+}
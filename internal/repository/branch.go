@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	errLocalChanges = errors.New("commit or discard the local changes, including untracked files, before checkout")
+)
+
+// CreateBranch creates a new branch which points to the commit of hash.
+func (m *RepositoryManager) CreateBranch(name, hash string) error {
+	if err := ValidateRefName(name); err != nil {
+		return err
+	}
+	if m.branchRef(name) != nil {
+		return fmt.Errorf("a branch named %q already exists", name)
+	}
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), plumbing.NewHash(hash))
+	if err := m.src.Storer.SetReference(ref); err != nil {
+		return err
+	}
+	m.addBranchRef(name, hash)
+	return nil
+}
+
+// RenameBranch renames the branch and its config, and keeps HEAD on it if it is checked out.
+func (m *RepositoryManager) RenameBranch(oldName, newName string) error {
+	if err := ValidateRefName(newName); err != nil {
+		return err
+	}
+	b := m.branchRef(oldName)
+	if b == nil {
+		return fmt.Errorf("branch not found: %s", oldName)
+	}
+	if m.branchRef(newName) != nil {
+		return fmt.Errorf("a branch named %q already exists", newName)
+	}
+	newRefName := plumbing.NewBranchReferenceName(newName)
+	if err := m.src.Storer.SetReference(plumbing.NewHashReference(newRefName, plumbing.NewHash(b.targetHash))); err != nil {
+		return err
+	}
+	if m.currentBranch == oldName {
+		if err := m.src.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, newRefName)); err != nil {
+			return err
+		}
+		m.currentBranch = newName
+	}
+	if err := m.src.Storer.RemoveReference(plumbing.NewBranchReferenceName(oldName)); err != nil {
+		return err
+	}
+	if err := m.renameBranchConfig(oldName, newName); err != nil {
+		return err
+	}
+	m.removeBranchRef(oldName)
+	// the config has been renamed with the branch, so it still tracks the same upstream
+	m.addBranchRef(newName, b.targetHash).upstream = b.upstream
+	return nil
+}
+
+func (m *RepositoryManager) renameBranchConfig(oldName, newName string) error {
+	cfg, err := m.src.Config()
+	if err != nil {
+		return err
+	}
+	b, ok := cfg.Branches[oldName]
+	if !ok {
+		return nil
+	}
+	delete(cfg.Branches, oldName)
+	b.Name = newName
+	cfg.Branches[newName] = b
+	return m.src.SetConfig(cfg)
+}
+
+// DeleteBranch deletes the branch and its config. The branch checked out cannot be deleted.
+func (m *RepositoryManager) DeleteBranch(name string) error {
+	if m.branchRef(name) == nil {
+		return fmt.Errorf("branch not found: %s", name)
+	}
+	if m.currentBranch == name {
+		return fmt.Errorf("cannot delete the branch %q which is checked out", name)
+	}
+	if err := m.src.Storer.RemoveReference(plumbing.NewBranchReferenceName(name)); err != nil {
+		return err
+	}
+	if err := m.src.DeleteBranch(name); err != nil && err != git.ErrBranchNotFound {
+		return err
+	}
+	m.removeBranchRef(name)
+	return nil
+}
+
+// IsMerged returns whether the commit of the branch is reachable from HEAD, like `git branch --merged`.
+func (m *RepositoryManager) IsMerged(name string) bool {
+	b := m.branchRef(name)
+	if b == nil || m.head == nil {
+		return false
+	}
+	return m.isAncestor(b.targetHash, m.head.targetHash)
+}
+
+func (m *RepositoryManager) isAncestor(ancestor, descendant string) bool {
+	if m.graphOption.FirstParent || m.graphOption.Revisions != "" {
+		// the graph may lack the other parents of merges or the ancestors out of the revisions
+		return m.isAncestorInObjects(ancestor, descendant)
+	}
+	visited := map[string]struct{}{descendant: {}}
+	queue := []string{descendant}
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if h == ancestor {
+			return true
+		}
+		for _, p := range m.ParentsHashes(h) {
+			if _, ok := visited[p]; !ok {
+				visited[p] = struct{}{}
+				queue = append(queue, p)
+			}
+		}
+	}
+	return false
+}
+
+func (m *RepositoryManager) isAncestorInObjects(ancestor, descendant string) bool {
+	a, err := m.src.CommitObject(plumbing.NewHash(ancestor))
+	if err != nil {
+		return false
+	}
+	d, err := m.src.CommitObject(plumbing.NewHash(descendant))
+	if err != nil {
+		return false
+	}
+	ok, err := a.IsAncestor(d)
+	return err == nil && ok
+}
+
+// CheckoutBranch switches HEAD and the worktree to the branch.
+// The worktree node follows HEAD, so the caller must reopen the repository to rebuild the graph.
+func (m *RepositoryManager) CheckoutBranch(name string) error {
+	if m.branchRef(name) == nil {
+		return fmt.Errorf("branch not found: %s", name)
+	}
+	return m.checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name)})
+}
+
+// CheckoutCommit detaches HEAD at the commit and switches the worktree to it.
+// The caller must reopen the repository like CheckoutBranch.
+func (m *RepositoryManager) CheckoutCommit(hash string) error {
+	return m.checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(hash)})
+}
+
+func (m *RepositoryManager) checkout(opts *git.CheckoutOptions) error {
+	w, err := m.src.Worktree()
+	if err != nil {
+		return err
+	}
+	// go-git moves HEAD before it checks the local changes, and removes untracked files,
+	// so the worktree must be clean
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	if !status.IsClean() {
+		return errLocalChanges
+	}
+	return w.Checkout(opts)
+}
+
+// LocalBranchesAt returns the names of the local branches which point to the commit.
+func (m *RepositoryManager) LocalBranchesAt(hash string) []string {
+	ret := make([]string, 0)
+	for _, b := range m.branchesMap[hash] {
+		ret = append(ret, b.name)
+	}
+	return ret
+}
+
+func (m *RepositoryManager) branchRef(name string) *Ref {
+	return fromRefNameFrom(m.branchesMap, name)
+}
+
+func (m *RepositoryManager) addBranchRef(name, hash string) *Ref {
+	b := &Ref{
+		refType:    Branch,
+		name:       name,
+		fullName:   plumbing.NewBranchReferenceName(name).String(),
+		targetHash: hash,
+	}
+	m.branchesMap[hash] = append(m.branchesMap[hash], b)
+	return b
+}
+
+func (m *RepositoryManager) removeBranchRef(name string) {
+	for hash, bs := range m.branchesMap {
+		for i, b := range bs {
+			if b.name != name {
+				continue
+			}
+			m.branchesMap[hash] = append(bs[:i:i], bs[i+1:]...)
+			if len(m.branchesMap[hash]) == 0 {
+				delete(m.branchesMap, hash)
+			}
+			return
+		}
+	}
+}
+
+// ValidateRefName checks the short name of a branch or a tag with the rules of `git check-ref-format --branch`.
+func ValidateRefName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid name %q: %s", name, reason)
+	}
+	switch {
+	case name == "":
+		return invalid("empty")
+	case name == "@" || name == "HEAD":
+		return invalid("reserved name")
+	case strings.HasPrefix(name, "-"):
+		return invalid("starts with '-'")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//"):
+		return invalid("empty path component")
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock"):
+		return invalid("ends with '.' or '.lock'")
+	case strings.Contains(name, "..") || strings.Contains(name, "@{"):
+		return invalid("contains '..' or '@{'")
+	case strings.ContainsAny(name, " ~^:?*[\\"):
+		return invalid("contains a forbidden character")
+	}
+	for _, c := range strings.Split(name, "/") {
+		if strings.HasPrefix(c, ".") {
+			return invalid("path component starts with '.'")
+		}
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return invalid("contains a control character")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+// Comparison is the difference between two commits.
+// The embedded repository has the commits reachable from either of them but not from both, like `git log a...b`.
+type Comparison struct {
+	*gogigu.Repository
+
+	from    *object.Commit
+	to      *object.Commit
+	details []*PatchFileDetail
+}
+
+func (c *Comparison) From() *object.Commit {
+	return c.from
+}
+
+func (c *Comparison) To() *object.Commit {
+	return c.to
+}
+
+// Details returns the changes from the tree of From to the tree of To, like `git diff a b`.
+func (c *Comparison) Details() []*PatchFileDetail {
+	return c.details
+}
+
+type ComparePhase int
+
+const (
+	ComparingCommits ComparePhase = iota
+	ComparingFiles
+)
+
+// CompareProgress is called with the number of commits read so far, which is 0 before commits are read.
+type CompareProgress func(phase ComparePhase, commits int)
+
+// Compare compares the commits of the revisions a and b, which can be hashes or ref names.
+// It runs in the background, so the objects are read through another handle than m's. progress may be nil.
+func (m *RepositoryManager) Compare(ctx context.Context, a, b string, progress CompareProgress) (*Comparison, error) {
+	if progress == nil {
+		progress = func(ComparePhase, int) {}
+	}
+	src, err := git.PlainOpen(m.path)
+	if err != nil {
+		return nil, err
+	}
+	ha, err := resolveRevision(src, a)
+	if err != nil {
+		return nil, err
+	}
+	hb, err := resolveRevision(src, b)
+	if err != nil {
+		return nil, err
+	}
+	from, err := src.CommitObject(ha)
+	if err != nil {
+		return nil, err
+	}
+	to, err := src.CommitObject(hb)
+	if err != nil {
+		return nil, err
+	}
+
+	progress(ComparingCommits, 0)
+	bases, err := mergeBases(src, ha, hb)
+	if err != nil {
+		return nil, err
+	}
+	opt := &gogigu.Option{Sort: m.graphOption.Sort, From: []plumbing.Hash{ha, hb}, Exclude: bases}
+	repo, err := gogigu.CalculateContext(ctx, src, opt, func(_ gogigu.Phase, commits int) {
+		progress(ComparingCommits, commits)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	progress(ComparingFiles, repo.Len())
+	details, err := m.patchFileDetailsFrom(from, to)
+	if err != nil {
+		return nil, err
+	}
+	CalculateLineStats(ctx, details, nil)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &Comparison{
+		Repository: repo,
+		from:       from,
+		to:         to,
+		details:    details,
+	}, nil
+}
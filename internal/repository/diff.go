@@ -0,0 +1,533 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	diffContextLines = 3
+)
+
+type DiffLineType int
+
+const (
+	_ DiffLineType = iota
+	DiffContext
+	DiffAdded
+	DiffDeleted
+)
+
+type DiffLine struct {
+	lineType  DiffLineType
+	content   string
+	oldLineNo int
+	newLineNo int
+
+	// changed is false for context lines, and also for lines of a combined diff
+	// which differ from some parents but not from all of them.
+	changed bool
+
+	// markers and oldLineNos are set only for combined diffs, one for each parent.
+	markers    string
+	oldLineNos []int
+}
+
+func (l *DiffLine) LineType() DiffLineType {
+	return l.lineType
+}
+
+func (l *DiffLine) Content() string {
+	return l.content
+}
+
+// OldLineNo returns 0 if the line does not exist in the old file.
+func (l *DiffLine) OldLineNo() int {
+	return l.oldLineNo
+}
+
+// NewLineNo returns 0 if the line does not exist in the new file.
+func (l *DiffLine) NewLineNo() int {
+	return l.newLineNo
+}
+
+// Markers returns the per-parent columns of a combined diff line like `+ ` or ` -`,
+// or an empty string if the line is not a part of a combined diff.
+func (l *DiffLine) Markers() string {
+	return l.markers
+}
+
+type DiffHunk struct {
+	oldStart int
+	oldLines int
+	newStart int
+	newLines int
+	lines    []*DiffLine
+
+	parentStarts []int
+	parentLines  []int
+}
+
+func (h *DiffHunk) Lines() []*DiffLine {
+	return h.lines
+}
+
+func (h *DiffHunk) Header() string {
+	if len(h.parentStarts) > 0 {
+		marks := strings.Repeat("@", len(h.parentStarts)+1)
+		ranges := make([]string, 0)
+		for i := range h.parentStarts {
+			ranges = append(ranges, "-"+hunkRange(h.parentStarts[i], h.parentLines[i]))
+		}
+		ranges = append(ranges, "+"+hunkRange(h.newStart, h.newLines))
+		return fmt.Sprintf("%s %s %s", marks, strings.Join(ranges, " "), marks)
+	}
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.oldStart, h.oldLines), hunkRange(h.newStart, h.newLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+type FileDiff struct {
+	name   string
+	binary bool
+	hunks  []*DiffHunk
+}
+
+func (d *FileDiff) Name() string {
+	return d.name
+}
+
+func (d *FileDiff) IsBinary() bool {
+	return d.binary
+}
+
+func (d *FileDiff) Hunks() []*DiffHunk {
+	return d.hunks
+}
+
+func (m *RepositoryManager) FileDiff(d *PatchFileDetail) (*FileDiff, error) {
+	fd := &FileDiff{
+		name:  d.name,
+		hunks: make([]*DiffHunk, 0),
+	}
+	lines, binary, err := d.diffLines()
+	if err != nil {
+		return nil, err
+	}
+	if binary {
+		fd.binary = true
+		return fd, nil
+	}
+	fd.hunks = buildHunks(lines, diffContextLines)
+	return fd, nil
+}
+
+func (d *PatchFileDetail) calculateLineStats() {
+	s := lineStats{done: true}
+	lines, binary, err := d.diffLines()
+	switch {
+	case err != nil:
+		s.err = err
+	case binary:
+		s.binary = true
+	default:
+		for _, l := range lines {
+			if !l.changed {
+				continue
+			}
+			switch l.lineType {
+			case DiffAdded:
+				s.added++
+			case DiffDeleted:
+				s.deleted++
+			}
+		}
+	}
+	d.mu.Lock()
+	d.stats = s
+	d.mu.Unlock()
+}
+
+func (d *PatchFileDetail) diffLines() ([]*DiffLine, bool, error) {
+	if d.IsCombined() {
+		return combinedDiffLines(d.parentChanges, d.source.changeContents)
+	}
+	if d.contents != nil {
+		if d.contents.binary {
+			return nil, true, nil
+		}
+		return diffLines(d.contents.from, d.contents.to), false, nil
+	}
+	from, to, binary, err := d.source.changeContents(d.change)
+	if err != nil || binary {
+		return nil, binary, err
+	}
+	return diffLines(from, to), false, nil
+}
+
+// patchSource serializes the reads of the objects of the details listed together,
+// since their line stats are calculated in the background while their diffs are shown.
+// Only the reads are serialized, so a large file being diffed does not block the others.
+type patchSource struct {
+	mu sync.Mutex
+}
+
+func (s *patchSource) changeContents(c *object.Change) (string, string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return changeContents(c)
+}
+
+func changeContents(c *object.Change) (string, string, bool, error) {
+	from, to, err := c.Files()
+	if err != nil {
+		return "", "", false, err
+	}
+	fromContent, fromBinary, err := fileContent(from)
+	if err != nil {
+		return "", "", false, err
+	}
+	toContent, toBinary, err := fileContent(to)
+	if err != nil {
+		return "", "", false, err
+	}
+	return fromContent, toContent, fromBinary || toBinary, nil
+}
+
+func fileContent(f *object.File) (string, bool, error) {
+	if f == nil {
+		return "", false, nil
+	}
+	binary, err := f.IsBinary()
+	if err != nil || binary {
+		return "", binary, err
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return "", false, err
+	}
+	return content, false, nil
+}
+
+// diffLines does not use utils/diff of go-git because the line encoding of
+// the go-diff version it depends on breaks files with more than 9 lines.
+func diffLines(from, to string) []*DiffLine {
+	fromRunes, toRunes, lineArray := linesToRunes(from, to)
+	dmp := diffmatchpatch.New()
+	// a diff cut off by the timeout is not minimal, so the time is not limited
+	dmp.DiffTimeout = 0
+	diffs := dmp.DiffMainRunes(fromRunes, toRunes, false)
+
+	lines := make([]*DiffLine, 0)
+	oldLineNo, newLineNo := 1, 1
+	for _, d := range diffs {
+		for _, r := range []rune(d.Text) {
+			l := &DiffLine{content: lineArray[runeToLineIndex(r)]}
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				l.lineType = DiffContext
+				l.oldLineNo = oldLineNo
+				l.newLineNo = newLineNo
+				oldLineNo++
+				newLineNo++
+			case diffmatchpatch.DiffInsert:
+				l.lineType = DiffAdded
+				l.newLineNo = newLineNo
+				l.changed = true
+				newLineNo++
+			case diffmatchpatch.DiffDelete:
+				l.lineType = DiffDeleted
+				l.oldLineNo = oldLineNo
+				l.changed = true
+				oldLineNo++
+			}
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// combinedDiffLines merges the diffs from each parent into the result like `git diff --cc`.
+// A line is regarded as changed only if it differs from all parents.
+// contents reads the files of each change.
+func combinedDiffLines(changes []*object.Change, contents func(*object.Change) (string, string, bool, error)) ([]*DiffLine, bool, error) {
+	n := len(changes)
+	var resultLines []string
+	parentDiffs := make([][]*DiffLine, n)
+	for i, c := range changes {
+		from, to, binary, err := contents(c)
+		if err != nil || binary {
+			return nil, binary, err
+		}
+		parentDiffs[i] = diffLines(from, to)
+		resultLines = splitLines(to)
+	}
+
+	added := make([][]bool, len(resultLines))
+	oldLineNos := make([][]int, len(resultLines))
+	for j := range resultLines {
+		added[j] = make([]bool, n)
+		oldLineNos[j] = make([]int, n)
+	}
+	// deleted[g][i] is the lines deleted from the parent i just before the result line g
+	deleted := make([][][]*DiffLine, len(resultLines)+1)
+	for g := range deleted {
+		deleted[g] = make([][]*DiffLine, n)
+	}
+	for i, ls := range parentDiffs {
+		j := 0
+		for _, l := range ls {
+			switch l.lineType {
+			case DiffContext:
+				oldLineNos[j][i] = l.oldLineNo
+				j++
+			case DiffAdded:
+				added[j][i] = true
+				j++
+			case DiffDeleted:
+				deleted[j][i] = append(deleted[j][i], l)
+			}
+		}
+	}
+
+	lines := make([]*DiffLine, 0)
+	for g := range deleted {
+		deletedFromAll := true
+		for i := 0; i < n; i++ {
+			if len(deleted[g][i]) == 0 {
+				deletedFromAll = false
+			}
+		}
+		lines = append(lines, combinedDeletedLines(deleted[g], deletedFromAll)...)
+		if g == len(resultLines) {
+			break
+		}
+		addedToAll, addedToAny := true, false
+		for i := 0; i < n; i++ {
+			addedToAll = addedToAll && added[g][i]
+			addedToAny = addedToAny || added[g][i]
+		}
+		l := &DiffLine{
+			lineType:   DiffContext,
+			content:    resultLines[g],
+			newLineNo:  g + 1,
+			changed:    addedToAll,
+			markers:    combinedMarkers(n, func(k int) byte { return markerIf(added[g][k], '+') }),
+			oldLineNos: oldLineNos[g],
+		}
+		if addedToAny {
+			l.lineType = DiffAdded
+		}
+		lines = append(lines, l)
+	}
+	return lines, false, nil
+}
+
+// combinedDeletedLines shows the same line deleted from several parents as one line.
+func combinedDeletedLines(deleted [][]*DiffLine, changed bool) []*DiffLine {
+	n := len(deleted)
+	lines := make([]*DiffLine, 0)
+	for i := 0; i < n; i++ {
+		cursor := 0
+		for _, dl := range deleted[i] {
+			found := false
+			for k := cursor; k < len(lines); k++ {
+				if lines[k].content == dl.content && lines[k].oldLineNos[i] == 0 {
+					lines[k].markers = lines[k].markers[:i] + "-" + lines[k].markers[i+1:]
+					lines[k].oldLineNos[i] = dl.oldLineNo
+					cursor = k + 1
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+			l := &DiffLine{
+				lineType:   DiffDeleted,
+				content:    dl.content,
+				changed:    changed,
+				markers:    combinedMarkers(n, func(k int) byte { return markerIf(k == i, '-') }),
+				oldLineNos: make([]int, n),
+			}
+			l.oldLineNos[i] = dl.oldLineNo
+			lines = append(lines, l)
+			cursor = len(lines)
+		}
+	}
+	return lines
+}
+
+func combinedMarkers(n int, f func(int) byte) string {
+	bs := make([]byte, n)
+	for i := range bs {
+		bs[i] = f(i)
+	}
+	return string(bs)
+}
+
+func markerIf(b bool, marker byte) byte {
+	if b {
+		return marker
+	}
+	return ' '
+}
+
+func linesToRunes(from, to string) ([]rune, []rune, []string) {
+	lineArray := make([]string, 0)
+	lineIndex := make(map[string]int)
+	toRunes := func(s string) []rune {
+		ls := splitLines(s)
+		rs := make([]rune, len(ls))
+		for i, l := range ls {
+			idx, ok := lineIndex[l]
+			if !ok {
+				idx = len(lineArray)
+				lineArray = append(lineArray, l)
+				lineIndex[l] = idx
+			}
+			rs[i] = lineIndexToRune(idx)
+		}
+		return rs
+	}
+	return toRunes(from), toRunes(to), lineArray
+}
+
+// runes in the surrogate range cannot survive the conversion to string in diffmatchpatch.
+const (
+	surrogateMin = 0xD800
+	surrogateMax = 0xDFFF
+)
+
+func lineIndexToRune(i int) rune {
+	if i >= surrogateMin {
+		return rune(i + surrogateMax - surrogateMin + 1)
+	}
+	return rune(i)
+}
+
+func runeToLineIndex(r rune) int {
+	if r > surrogateMax {
+		return int(r) - (surrogateMax - surrogateMin + 1)
+	}
+	return int(r)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func buildHunks(lines []*DiffLine, context int) []*DiffHunk {
+	hunks := make([]*DiffHunk, 0)
+	var current *DiffHunk
+	lastChanged := -1
+	for i, l := range lines {
+		if !l.changed {
+			continue
+		}
+		from := i - context
+		if from < 0 {
+			from = 0
+		}
+		if current != nil && from <= lastChanged+context+1 {
+			current.lines = append(current.lines, lines[lastChanged+1:i+1]...)
+		} else {
+			if current != nil {
+				current.lines = append(current.lines, trailingContext(lines, lastChanged, context)...)
+				hunks = append(hunks, current)
+			}
+			current = &DiffHunk{
+				lines: append(make([]*DiffLine, 0), lines[from:i+1]...),
+			}
+		}
+		lastChanged = i
+	}
+	if current != nil {
+		current.lines = append(current.lines, trailingContext(lines, lastChanged, context)...)
+		hunks = append(hunks, current)
+	}
+	for _, h := range hunks {
+		h.calculateRange()
+	}
+	return hunks
+}
+
+func trailingContext(lines []*DiffLine, lastChanged, context int) []*DiffLine {
+	to := lastChanged + 1 + context
+	if to > len(lines) {
+		to = len(lines)
+	}
+	return lines[lastChanged+1 : to]
+}
+
+func (h *DiffHunk) calculateRange() {
+	if len(h.lines) > 0 && len(h.lines[0].oldLineNos) > 0 {
+		h.calculateCombinedRange()
+	}
+	for _, l := range h.lines {
+		if l.oldLineNo > 0 {
+			if h.oldStart == 0 {
+				h.oldStart = l.oldLineNo
+			}
+			h.oldLines++
+		}
+		if l.newLineNo > 0 {
+			if h.newStart == 0 {
+				h.newStart = l.newLineNo
+			}
+			h.newLines++
+		}
+	}
+	if h.oldLines == 0 {
+		h.oldStart = h.firstNewLineNo() - 1
+	}
+	if h.newLines == 0 {
+		h.newStart = h.firstOldLineNo() - 1
+	}
+}
+
+func (h *DiffHunk) firstOldLineNo() int {
+	for _, l := range h.lines {
+		if l.oldLineNo > 0 {
+			return l.oldLineNo
+		}
+	}
+	return 1
+}
+
+func (h *DiffHunk) firstNewLineNo() int {
+	for _, l := range h.lines {
+		if l.newLineNo > 0 {
+			return l.newLineNo
+		}
+	}
+	return 1
+}
+
+func (h *DiffHunk) calculateCombinedRange() {
+	n := len(h.lines[0].oldLineNos)
+	h.parentStarts = make([]int, n)
+	h.parentLines = make([]int, n)
+	for i := 0; i < n; i++ {
+		for _, l := range h.lines {
+			if l.oldLineNos[i] > 0 {
+				if h.parentStarts[i] == 0 {
+					h.parentStarts[i] = l.oldLineNos[i]
+				}
+				h.parentLines[i]++
+			}
+		}
+	}
+}
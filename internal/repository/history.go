@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+type FileHistory struct {
+	*gogigu.Repository
+
+	paths map[string]string
+}
+
+// Path returns the path of the file at the commit, which differs from the
+// requested path if the file has been renamed since then.
+func (h *FileHistory) Path(hash string) string {
+	return h.paths[hash]
+}
+
+// FileHistory returns the commits which changed the file at path, starting from the target commit.
+// Renames are followed like `git log --follow`, and a merge commit is included only if
+// the file differs from all parents.
+func (m *RepositoryManager) FileHistory(target *gogigu.Node, path string) (*FileHistory, error) {
+	nodes, err := m.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	tracked := map[string]string{target.Hash(): path}
+	hashes := make([]string, 0)
+	paths := make(map[string]string)
+	for _, n := range nodes {
+		p, ok := tracked[n.Hash()]
+		if !ok {
+			continue
+		}
+		h, err := fileHash(n.Commit, p)
+		if err != nil {
+			return nil, err
+		}
+		parentPaths, changed, err := m.followParents(n, p, h)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			hashes = append(hashes, n.Hash())
+			paths[n.Hash()] = p
+		}
+		for parentHash, parentPath := range parentPaths {
+			if _, ok := tracked[parentHash]; !ok {
+				tracked[parentHash] = parentPath
+			}
+		}
+	}
+	sub := m.Repository.Subset(hashes, &gogigu.Option{Sort: m.graphOption.Sort})
+	return &FileHistory{Repository: sub, paths: paths}, nil
+}
+
+// followParents returns the path of the file in the parents to follow, and whether the commit changed the file.
+func (m *RepositoryManager) followParents(n *gogigu.Node, path string, hash plumbing.Hash) (map[string]string, bool, error) {
+	// the parents out of the revisions shown still decide whether the commit changed the file
+	ps, err := parentCommits(graphCommits(m.src, m.Repository), n.Commit)
+	if err != nil {
+		return nil, false, err
+	}
+	if m.graphOption.FirstParent && len(ps) > 1 {
+		ps = ps[:1]
+	}
+	if len(ps) == 0 {
+		return map[string]string{}, !hash.IsZero(), nil
+	}
+	parentPaths := make(map[string]string)
+	for _, p := range ps {
+		ph, err := fileHash(p, path)
+		if err != nil {
+			return nil, false, err
+		}
+		if ph == hash {
+			// same as one of the parents, so only the history of that parent matters
+			if hash.IsZero() {
+				return map[string]string{}, false, nil
+			}
+			return map[string]string{p.Hash.String(): path}, false, nil
+		}
+		if !ph.IsZero() {
+			parentPaths[p.Hash.String()] = path
+			continue
+		}
+		oldPath, err := m.renamedFrom(p, n.Commit, path)
+		if err != nil {
+			return nil, false, err
+		}
+		if oldPath != "" {
+			parentPaths[p.Hash.String()] = oldPath
+		}
+	}
+	return parentPaths, true, nil
+}
+
+func (m *RepositoryManager) renamedFrom(parent, target *object.Commit, path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	changes, err := m.treeChanges(parent, target)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range changes {
+		if c.changeType == Move && c.To.Name == path {
+			return c.From.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// fileHash returns plumbing.ZeroHash if the file does not exist.
+func fileHash(c *object.Commit, path string) (plumbing.Hash, error) {
+	t, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	e, err := t.FindEntry(path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return e.Hash, nil
+}
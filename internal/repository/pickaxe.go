@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+type PickaxeMode int
+
+const (
+	// PickaxeOccurrences finds commits which change the number of occurrences of the string, like `git log -S`.
+	PickaxeOccurrences PickaxeMode = iota
+	// PickaxeLines finds commits which add or remove lines matching the regexp, like `git log -G`.
+	PickaxeLines
+)
+
+const (
+	// pickaxeProgressInterval is the number of commits checked between progress reports.
+	pickaxeProgressInterval = 100
+)
+
+var (
+	errEmptyPickaxePattern = errors.New("pattern must not be empty")
+)
+
+type PickaxeQuery struct {
+	Pattern string
+	Mode    PickaxeMode
+}
+
+// Pickaxe returns the graph of the commits whose diff against the first parent matches the query.
+// Merge commits are skipped like `git log` without `-m`, and binary files are ignored.
+// progress is called with the number of commits checked so far.
+// It runs in the background, so the objects are read through another handle than m's.
+func (m *RepositoryManager) Pickaxe(ctx context.Context, q *PickaxeQuery, progress func(done, total int)) (*gogigu.Repository, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+	src, err := git.PlainOpen(m.path)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := m.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0)
+	for i, n := range nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if i%pickaxeProgressInterval == 0 {
+			progress(i, len(nodes))
+		}
+		if n.IsWorktree() || len(n.Commit.ParentHashes) > 1 {
+			continue
+		}
+		ok, err := pickaxeMatches(ctx, src, n, match)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			hashes = append(hashes, n.Hash())
+		}
+	}
+	progress(len(nodes), len(nodes))
+	return m.Repository.Subset(hashes, &gogigu.Option{Sort: m.graphOption.Sort}), nil
+}
+
+func pickaxeMatches(ctx context.Context, src *git.Repository, n *gogigu.Node, match func(from, to string) bool) (bool, error) {
+	c, err := src.CommitObject(n.Commit.Hash)
+	if err != nil {
+		return false, err
+	}
+	tt, err := c.Tree()
+	if err != nil {
+		return false, err
+	}
+	var ft *object.Tree
+	if len(c.ParentHashes) > 0 {
+		// the parent may be out of the revisions shown, so it is read from the commit
+		p, err := c.Parent(0)
+		if err != nil {
+			return false, err
+		}
+		ft, err = p.Tree()
+		if err != nil {
+			return false, err
+		}
+	}
+	changes, err := object.DiffTreeContext(ctx, ft, tt)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range changes {
+		from, to, binary, err := changeContents(c)
+		if err != nil {
+			return false, err
+		}
+		if !binary && match(from, to) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (q *PickaxeQuery) matcher() (func(from, to string) bool, error) {
+	if q.Pattern == "" {
+		return nil, errEmptyPickaxePattern
+	}
+	if q.Mode == PickaxeOccurrences {
+		return func(from, to string) bool {
+			return strings.Count(from, q.Pattern) != strings.Count(to, q.Pattern)
+		}, nil
+	}
+	re, err := regexp.Compile(q.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	// matching whole contents first is much faster than diffing every file
+	multiline := regexp.MustCompile("(?m)" + q.Pattern)
+	return func(from, to string) bool {
+		if !multiline.MatchString(from) && !multiline.MatchString(to) {
+			return false
+		}
+		for _, l := range diffLines(from, to) {
+			if l.changed && re.MatchString(l.content) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
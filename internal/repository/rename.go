@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+const (
+	DefaultRenameThreshold = 50
+
+	// renameLimit is the maximum number of sources and destinations compared by content, like diff.renameLimit.
+	renameLimit = 1000
+)
+
+var (
+	emptyBlobHash = plumbing.ComputeHash(plumbing.BlobObject, []byte{})
+)
+
+type detectedChange struct {
+	*object.Change
+	changeType ChangeType
+	similarity int
+}
+
+type renameCandidate struct {
+	change *object.Change
+	entry  object.ChangeEntry
+	file   *object.File
+	// lines counts the lines of the content, which are read only for the pairs of similar sizes
+	lines  map[string]int
+	binary bool
+	opened bool
+	loaded bool
+}
+
+// open reads the size of the file, but not its content yet.
+func (c *renameCandidate) open() error {
+	if c.opened {
+		return nil
+	}
+	c.opened = true
+	if !c.entry.TreeEntry.Mode.IsFile() {
+		c.binary = true
+		return nil
+	}
+	f, err := c.entry.Tree.TreeEntryFile(&c.entry.TreeEntry)
+	if err != nil {
+		return err
+	}
+	c.file = f
+	return nil
+}
+
+func (c *renameCandidate) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.loaded = true
+	if err := c.open(); err != nil {
+		return err
+	}
+	if c.binary {
+		return nil
+	}
+	content, binary, err := fileContent(c.file)
+	if err != nil {
+		return err
+	}
+	c.binary = binary
+	c.lines = make(map[string]int)
+	for _, l := range splitLines(content) {
+		c.lines[l]++
+	}
+	return nil
+}
+
+// detectRenames merges pairs of deletion and insertion into renames,
+// and marks insertions copied from modified or deleted files as copies.
+// threshold is the minimum similarity in percent.
+func detectRenames(changes object.Changes, threshold int) ([]*detectedChange, error) {
+	deleted := make([]*renameCandidate, 0)
+	inserted := make([]*renameCandidate, 0)
+	modified := make([]*renameCandidate, 0)
+	for _, c := range changes {
+		a, err := c.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch a {
+		case merkletrie.Delete:
+			deleted = append(deleted, &renameCandidate{change: c, entry: c.From})
+		case merkletrie.Insert:
+			inserted = append(inserted, &renameCandidate{change: c, entry: c.To})
+		case merkletrie.Modify:
+			modified = append(modified, &renameCandidate{change: c, entry: c.From})
+		}
+	}
+
+	renamedFrom := make(map[*object.Change]*renameCandidate)
+	renamedTo := make(map[*object.Change]*detectedChange)
+	pairs, err := renamePairs(deleted, inserted, threshold)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pairs {
+		if _, ok := renamedFrom[p.src.change]; ok {
+			continue
+		}
+		if _, ok := renamedTo[p.dst.change]; ok {
+			continue
+		}
+		renamedFrom[p.src.change] = p.src
+		renamedTo[p.dst.change] = &detectedChange{
+			Change:     &object.Change{From: p.src.entry, To: p.dst.entry},
+			changeType: Move,
+			similarity: p.similarity,
+		}
+	}
+
+	sources := append(modified, deleted...)
+	for _, dst := range inserted {
+		if _, ok := renamedTo[dst.change]; ok {
+			continue
+		}
+		p, err := bestCopySource(sources, dst, threshold)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			continue
+		}
+		renamedTo[dst.change] = &detectedChange{
+			Change:     &object.Change{From: p.src.entry, To: dst.entry},
+			changeType: Copy,
+			similarity: p.similarity,
+		}
+	}
+
+	ret := make([]*detectedChange, 0, len(changes))
+	for _, c := range changes {
+		if _, ok := renamedFrom[c]; ok {
+			continue
+		}
+		if dc, ok := renamedTo[c]; ok {
+			ret = append(ret, dc)
+			continue
+		}
+		changeType, err := changeTypeFrom(c)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &detectedChange{Change: c, changeType: changeType})
+	}
+	return ret, nil
+}
+
+type renamePair struct {
+	src        *renameCandidate
+	dst        *renameCandidate
+	similarity int
+}
+
+// renamePairs returns the candidate pairs sorted by similarity, exact renames first.
+func renamePairs(srcs, dsts []*renameCandidate, threshold int) ([]*renamePair, error) {
+	pairs := make([]*renamePair, 0)
+	compareContents := len(srcs) <= renameLimit && len(dsts) <= renameLimit
+	for _, dst := range dsts {
+		for _, src := range srcs {
+			p, err := newRenamePair(src, dst, threshold, compareContents)
+			if err != nil {
+				return nil, err
+			}
+			if p.similarity >= threshold {
+				pairs = append(pairs, p)
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].similarity > pairs[j].similarity
+	})
+	return pairs, nil
+}
+
+func bestCopySource(srcs []*renameCandidate, dst *renameCandidate, threshold int) (*renamePair, error) {
+	var best *renamePair
+	compareContents := len(srcs) <= renameLimit
+	for _, src := range srcs {
+		p, err := newRenamePair(src, dst, threshold, compareContents)
+		if err != nil {
+			return nil, err
+		}
+		if p.similarity >= threshold && (best == nil || p.similarity > best.similarity) {
+			best = p
+		}
+	}
+	return best, nil
+}
+
+func newRenamePair(src, dst *renameCandidate, threshold int, compareContents bool) (*renamePair, error) {
+	p := &renamePair{src: src, dst: dst}
+	if dst.entry.TreeEntry.Hash == emptyBlobHash {
+		return p, nil
+	}
+	if src.entry.TreeEntry.Hash == dst.entry.TreeEntry.Hash {
+		p.similarity = 100
+		return p, nil
+	}
+	if !compareContents {
+		return p, nil
+	}
+	if err := src.open(); err != nil {
+		return nil, err
+	}
+	if err := dst.open(); err != nil {
+		return nil, err
+	}
+	if src.binary || dst.binary {
+		return p, nil
+	}
+	// like git, the contents are not read if the sizes differ too much to be similar
+	if !similarSizes(src.file.Size, dst.file.Size, threshold) {
+		return p, nil
+	}
+	if err := src.load(); err != nil {
+		return nil, err
+	}
+	if err := dst.load(); err != nil {
+		return nil, err
+	}
+	if src.binary || dst.binary {
+		return p, nil
+	}
+	p.similarity = similarity(src.lines, dst.lines, src.file.Size, dst.file.Size)
+	// only identical files are regarded as 100% similar
+	if p.similarity == 100 {
+		p.similarity = 99
+	}
+	return p, nil
+}
+
+// similarSizes reports whether the contents of the sizes can reach the threshold of similarity,
+// since at most the size of the smaller one, and a missing newline at its end, is found in the larger one.
+func similarSizes(a, b int64, threshold int) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return (a+1)*100 >= b*int64(threshold)
+}
+
+// similarity returns the percentage of the bytes of the larger content
+// which are found as a whole line in the other content.
+// a and b count the lines of the contents, whose sizes are sizeA and sizeB.
+func similarity(a, b map[string]int, sizeA, sizeB int64) int {
+	size := sizeA
+	if sizeB > size {
+		size = sizeB
+	}
+	if size == 0 {
+		return 100
+	}
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var common int64
+	for l, n := range a {
+		if m := b[l]; m < n {
+			n = m
+		}
+		common += int64(n * (len(l) + 1))
+	}
+	if common > size {
+		common = size
+	}
+	return int(common * 100 / size)
+}
@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/go-git/go-git/v5"
@@ -20,12 +23,20 @@ const (
 	Branch RefType = iota
 	RemoteBranch
 	Tag
+	Head
 )
 
 type Ref struct {
-	refType    RefType
-	name       string
+	refType RefType
+	name    string
+	// fullName is like `refs/heads/main`, and `HEAD` for the HEAD ref.
+	fullName   string
 	targetHash string
+
+	// tag is set only for annotated tags.
+	tag *object.Tag
+	// upstream is set only for local branches which track other branches.
+	upstream *upstream
 }
 
 func (r *Ref) RefType() RefType {
@@ -36,22 +47,72 @@ func (r *Ref) Name() string {
 	return r.name
 }
 
+// FullName returns the name which is unique among the refs, like `refs/tags/v1.0`.
+func (r *Ref) FullName() string {
+	return r.fullName
+}
+
 func (r *Ref) TargetHash() string {
 	return r.targetHash
 }
 
+func (r *Ref) IsAnnotated() bool {
+	return r.tag != nil
+}
+
+// Tagger returns the zero value unless the ref is an annotated tag.
+func (r *Ref) Tagger() object.Signature {
+	if r.tag == nil {
+		return object.Signature{}
+	}
+	return r.tag.Tagger
+}
+
+// TagMessage returns an empty string unless the ref is an annotated tag.
+func (r *Ref) TagMessage() string {
+	if r.tag == nil {
+		return ""
+	}
+	return r.tag.Message
+}
+
 type RepositoryManager struct {
 	*gogigu.Repository
 
+	src *git.Repository
+
 	branchesMap map[string][]*Ref
 	remotesMap  map[string][]*Ref
 	tagsMap     map[string][]*Ref
 
+	// head is nil if HEAD does not point to any commit yet.
+	head          *Ref
+	currentBranch string
+
+	// path is where the repository is opened from, to open another handle of it.
+	// go-git storage is not safe for concurrent use, so a goroutine must not share src with the UI.
+	path string
 	name string
+	// renameThreshold is read atomically, since the details of commits are listed in the background
+	renameThreshold int32
+	graphOption     GraphOption
+}
+
+// GraphOption is how the commits are ordered and connected in the graph.
+type GraphOption struct {
+	Sort gogigu.Sort
+	// FirstParent shows only the mainline history which follows the first parent of each merge.
+	FirstParent bool
+	// Revisions limits the commits to those reachable from the revisions, like `main feature` or `main..feature`.
+	// All refs are shown if it is empty.
+	Revisions string
 }
 
 func (m *RepositoryManager) AllRefs(hash string) []*Ref {
 	refs := make([]*Ref, 0)
+	if m.head != nil && m.head.targetHash == hash {
+		refs = append(refs, m.head)
+	}
 	if ts, ok := m.tagsMap[hash]; ok {
 		refs = append(refs, ts...)
 	}
@@ -64,6 +125,19 @@ func (m *RepositoryManager) AllRefs(hash string) []*Ref {
 	return refs
 }
 
+func (m *RepositoryManager) HeadRef() *Ref {
+	return m.head
+}
+
+// CurrentBranch returns the short name of the branch which HEAD points to, or an empty string if HEAD is detached.
+func (m *RepositoryManager) CurrentBranch() string {
+	return m.currentBranch
+}
+
+func (m *RepositoryManager) IsDetached() bool {
+	return m.currentBranch == ""
+}
+
 func (m *RepositoryManager) BranchNames() []string {
 	ret := make([]string, 0)
 	for _, bs := range m.branchesMap {
@@ -118,19 +192,66 @@ func (m *RepositoryManager) SortedTagNames() []string {
 	return ret
 }
 
-func (m *RepositoryManager) FromRefName(name string) *Ref {
-	if ref := fromRefNameFrom(m.branchesMap, name); ref != nil {
-		return ref
+func (m *RepositoryManager) BranchRefs() []*Ref {
+	return refsInOrder(m.branchesMap, m.BranchNames())
+}
+
+func (m *RepositoryManager) RemoteBranchRefs() []*Ref {
+	return refsInOrder(m.remotesMap, m.RemoteBranchNames())
+}
+
+// SortedTagRefs returns the tags in the order of SortedTagNames.
+func (m *RepositoryManager) SortedTagRefs() []*Ref {
+	return refsInOrder(m.tagsMap, m.SortedTagNames())
+}
+
+func refsInOrder(refs map[string][]*Ref, names []string) []*Ref {
+	byName := make(map[string]*Ref)
+	for _, rs := range refs {
+		for _, r := range rs {
+			byName[r.name] = r
+		}
 	}
-	if ref := fromRefNameFrom(m.remotesMap, name); ref != nil {
-		return ref
+	ret := make([]*Ref, 0, len(names))
+	for _, n := range names {
+		ret = append(ret, byName[n])
 	}
-	if ref := fromRefNameFrom(m.tagsMap, name); ref != nil {
-		return ref
+	return ret
+}
+
+// FromFullRefName returns the ref whose full name is fullName, or nil if there is no such ref.
+func (m *RepositoryManager) FromFullRefName(fullName string) *Ref {
+	for _, refs := range []map[string][]*Ref{m.branchesMap, m.remotesMap, m.tagsMap} {
+		for _, rs := range refs {
+			for _, r := range rs {
+				if r.fullName == fullName {
+					return r
+				}
+			}
+		}
 	}
 	return nil
 }
 
+// Revision returns the short name of the ref if no other branch or tag has the same short name, or the full name otherwise,
+// so that the revision always resolves to the ref.
+func (m *RepositoryManager) Revision(ref *Ref) string {
+	n := 0
+	for _, refs := range []map[string][]*Ref{m.branchesMap, m.remotesMap, m.tagsMap} {
+		for _, rs := range refs {
+			for _, r := range rs {
+				if r.name == ref.name {
+					n++
+				}
+			}
+		}
+	}
+	if n > 1 {
+		return ref.fullName
+	}
+	return ref.name
+}
+
 func fromRefNameFrom(refs map[string][]*Ref, name string) *Ref {
 	for _, rs := range refs {
 		for _, r := range rs {
@@ -142,39 +263,141 @@ func fromRefNameFrom(refs map[string][]*Ref, name string) *Ref {
 	return nil
 }
 
+type LoadPhase int
+
+const (
+	LoadingWorktreeStatus LoadPhase = iota
+	LoadingCommits
+	LoadingLayout
+	LoadingRefs
+)
+
+// LoadProgress is called with the number of commits read so far, which is 0 before commits are read.
+type LoadProgress func(phase LoadPhase, commits int)
+
 func OpenGitRepository(path string) (*RepositoryManager, error) {
+	return OpenGitRepositoryContext(context.Background(), path, nil)
+}
+
+// OpenGitRepositoryContext stops loading when ctx is canceled. progress may be nil.
+func OpenGitRepositoryContext(ctx context.Context, path string, progress LoadProgress) (*RepositoryManager, error) {
 	src, err := git.PlainOpen(path)
 	if err != nil {
 		return nil, err
 	}
 
-	repo, err := gogigu.Calculate(src, &gogigu.Option{Sort: gogigu.CommitDate})
-	if err != nil {
+	rm := &RepositoryManager{
+		src:             src,
+		path:            path,
+		name:            filepath.Base(path),
+		renameThreshold: DefaultRenameThreshold,
+		graphOption:     GraphOption{Sort: gogigu.CommitDate},
+	}
+	if err := rm.load(ctx, progress); err != nil {
 		return nil, err
 	}
+	return rm, nil
+}
 
-	branches, remotes, tags, err := getReferences(src)
-	if err != nil {
-		return nil, err
+// RepositoryPathFromArgs returns an empty string if no path is given.
+func RepositoryPathFromArgs(args []string) string {
+	if len(args) <= 1 {
+		return ""
 	}
+	return args[1]
+}
 
-	name := filepath.Base(path)
+// Reopen loads the repository into a new manager with the same settings, leaving m as it is.
+func (m *RepositoryManager) Reopen(ctx context.Context, progress LoadProgress) (*RepositoryManager, error) {
+	return m.ReopenWithGraphOption(ctx, m.graphOption, progress)
+}
 
+// ReopenWithGraphOption is like Reopen, but lays out the graph with opt.
+func (m *RepositoryManager) ReopenWithGraphOption(ctx context.Context, opt GraphOption, progress LoadProgress) (*RepositoryManager, error) {
+	// the new manager is loaded in the background while m is still used
+	src, err := git.PlainOpen(m.path)
+	if err != nil {
+		return nil, err
+	}
 	rm := &RepositoryManager{
-		Repository:  repo,
-		branchesMap: branches,
-		remotesMap:  remotes,
-		tagsMap:     tags,
-		name:        name,
+		src:             src,
+		path:            m.path,
+		name:            m.name,
+		renameThreshold: m.renameThreshold,
+		graphOption:     opt,
+	}
+	if err := rm.load(ctx, progress); err != nil {
+		return nil, err
 	}
 	return rm, nil
 }
 
-func OpenGitRepositoryFromArgs(args []string) (*RepositoryManager, error) {
-	if len(args) <= 1 {
-		return nil, nil
+func (m *RepositoryManager) load(ctx context.Context, progress LoadProgress) error {
+	if progress == nil {
+		progress = func(LoadPhase, int) {}
+	}
+
+	progress(LoadingWorktreeStatus, 0)
+	dirty, err := hasUncommittedChanges(m.src)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	progress(LoadingCommits, 0)
+	opt := &gogigu.Option{
+		Sort:        m.graphOption.Sort,
+		FirstParent: m.graphOption.FirstParent,
+		Worktree:    dirty,
+		Lazy:        true,
+	}
+	if m.graphOption.Revisions != "" {
+		opt.From, opt.Exclude, err = m.resolveRevisions(m.graphOption.Revisions)
+		if err != nil {
+			return err
+		}
+	}
+	if opt.Sort == gogigu.Chronological {
+		// the rest of the history is read as the rows are shown, while m.src is used by the UI
+		opt.StreamSource, err = git.PlainOpen(m.path)
+		if err != nil {
+			return err
+		}
+	}
+	repo, err := gogigu.CalculateContext(ctx, m.src, opt, func(phase gogigu.Phase, commits int) {
+		if phase == gogigu.LayingOut {
+			progress(LoadingLayout, commits)
+		} else {
+			progress(LoadingCommits, commits)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	progress(LoadingRefs, repo.Len())
+	branches, remotes, tags, err := getReferences(m.src)
+	if err != nil {
+		return err
+	}
+	if err := trackUpstreams(ctx, m.src, repo, branches); err != nil {
+		return err
+	}
+
+	head, currentBranch, err := getHead(m.src)
+	if err != nil {
+		return err
 	}
-	return OpenGitRepository(args[1])
+
+	m.Repository = repo
+	m.branchesMap = branches
+	m.remotesMap = remotes
+	m.tagsMap = tags
+	m.head = head
+	m.currentBranch = currentBranch
+	return nil
 }
 
 func getReferences(src *git.Repository) (map[string][]*Ref, map[string][]*Ref, map[string][]*Ref, error) {
@@ -198,6 +421,7 @@ func getReferences(src *git.Repository) (map[string][]*Ref, map[string][]*Ref, m
 			branch := &Ref{
 				refType:    Branch,
 				name:       r.Name().Short(),
+				fullName:   r.Name().String(),
 				targetHash: hash,
 			}
 			bm[hash] = append(bm[hash], branch)
@@ -211,21 +435,21 @@ func getReferences(src *git.Repository) (map[string][]*Ref, map[string][]*Ref, m
 			remote := &Ref{
 				refType:    RemoteBranch,
 				name:       r.Name().Short(),
+				fullName:   r.Name().String(),
 				targetHash: hash,
 			}
 			rm[hash] = append(rm[hash], remote)
 		} else if r.Name().IsTag() {
-			if _, ok := tm[hash]; !ok {
-				tm[hash] = make([]*Ref, 0)
+			tag := &Ref{
+				refType:  Tag,
+				name:     r.Name().Short(),
+				fullName: r.Name().String(),
 			}
 			if at, ok := annotatedTags[hash]; ok {
 				hash = at.Target.String()
+				tag.tag = at
 			}
-			tag := &Ref{
-				refType:    Tag,
-				name:       r.Name().Short(),
-				targetHash: hash,
-			}
+			tag.targetHash = hash
 			tm[hash] = append(tm[hash], tag)
 		}
 		return nil
@@ -233,6 +457,33 @@ func getReferences(src *git.Repository) (map[string][]*Ref, map[string][]*Ref, m
 	return bm, rm, tm, nil
 }
 
+// getHead returns the HEAD ref and the short name of the branch which HEAD points to.
+// The branch name is empty if HEAD is detached, and the ref is nil if the branch has no commits yet.
+func getHead(src *git.Repository) (*Ref, string, error) {
+	sym, err := src.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return nil, "", err
+	}
+	currentBranch := ""
+	if sym.Type() == plumbing.SymbolicReference && sym.Target().IsBranch() {
+		currentBranch = sym.Target().Short()
+	}
+	resolved, err := src.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, currentBranch, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	head := &Ref{
+		refType:    Head,
+		name:       plumbing.HEAD.String(),
+		fullName:   plumbing.HEAD.String(),
+		targetHash: resolved.Hash().String(),
+	}
+	return head, currentBranch, nil
+}
+
 func annotatedTagsMap(src *git.Repository) (map[string]*object.Tag, error) {
 	tags, err := src.TagObjects()
 	if err != nil {
@@ -248,17 +499,135 @@ func annotatedTagsMap(src *git.Repository) (map[string]*object.Tag, error) {
 
 type PatchFileDetail struct {
 	name       string
+	oldName    string
 	changeType ChangeType
+	similarity int
+	untracked  bool
+	staged     bool
+	unstaged   bool
+	change     *object.Change
+
+	// source reads the objects of change and parentChanges.
+	source *patchSource
+
+	// mu guards stats, which CalculateLineStats sets in the background.
+	mu    sync.Mutex
+	stats lineStats
+
+	// contents is set instead of change for the uncommitted changes in the worktree.
+	contents *fileContents
+
+	// parentChanges is set only for the combined diff of a merge commit.
+	parentChanges []*object.Change
 }
 
 func (d *PatchFileDetail) Name() string {
 	return d.name
 }
 
+// OldName returns the same name as Name unless the file is renamed or copied.
+func (d *PatchFileDetail) OldName() string {
+	return d.oldName
+}
+
 func (d *PatchFileDetail) ChangeType() ChangeType {
 	return d.changeType
 }
 
+// Similarity returns the similarity in percent between the old and new file of a rename or copy.
+func (d *PatchFileDetail) Similarity() int {
+	return d.similarity
+}
+
+// lineStats is zero until it is calculated.
+type lineStats struct {
+	added   int
+	deleted int
+	binary  bool
+	err     error
+	done    bool
+}
+
+func (d *PatchFileDetail) lineStats() lineStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+func (d *PatchFileDetail) Added() int {
+	return d.lineStats().added
+}
+
+func (d *PatchFileDetail) Deleted() int {
+	return d.lineStats().deleted
+}
+
+func (d *PatchFileDetail) IsBinary() bool {
+	return d.lineStats().binary
+}
+
+// LineStatsCalculated reports whether Added, Deleted and IsBinary are known yet.
+func (d *PatchFileDetail) LineStatsCalculated() bool {
+	return d.lineStats().done
+}
+
+// LineStatsError returns the error which the file could not be diffed with, if any.
+func (d *PatchFileDetail) LineStatsError() error {
+	return d.lineStats().err
+}
+
+func (d *PatchFileDetail) IsUntracked() bool {
+	return d.untracked
+}
+
+// HasStagedChanges returns whether the index differs from HEAD, only for the worktree.
+func (d *PatchFileDetail) HasStagedChanges() bool {
+	return d.staged
+}
+
+// HasUnstagedChanges returns whether the worktree differs from the index, only for the worktree.
+func (d *PatchFileDetail) HasUnstagedChanges() bool {
+	return d.unstaged
+}
+
+func (d *PatchFileDetail) IsCombined() bool {
+	return len(d.parentChanges) > 0
+}
+
+// TotalLineStats sums the line stats calculated so far.
+func TotalLineStats(ds []*PatchFileDetail) (added, deleted int) {
+	for _, d := range ds {
+		s := d.lineStats()
+		added += s.added
+		deleted += s.deleted
+	}
+	return
+}
+
+const (
+	// lineStatsProgressInterval is the number of files calculated between progress reports.
+	lineStatsProgressInterval = 20
+)
+
+// CalculateLineStats diffs the files one by one, so that a large file does not keep the others from being shown.
+// An error of a file is kept in the file instead of stopping the others.
+// progress is called with the number of files calculated so far, and may be nil.
+func CalculateLineStats(ctx context.Context, ds []*PatchFileDetail, progress func(done int)) {
+	if progress == nil {
+		progress = func(int) {}
+	}
+	for i, d := range ds {
+		if ctx.Err() != nil {
+			return
+		}
+		d.calculateLineStats()
+		if (i+1)%lineStatsProgressInterval == 0 {
+			progress(i + 1)
+		}
+	}
+	progress(len(ds))
+}
+
 type ChangeType int
 
 const (
@@ -267,40 +636,161 @@ const (
 	Insert
 	Delete
 	Move
+	Copy
 )
 
-func (m *RepositoryManager) PatchFileDetails(target *gogigu.Node) ([]*PatchFileDetail, error) {
-	ps := m.Parents(target.Hash())
-	if len(ps) == 0 {
-		return []*PatchFileDetail{}, nil
+func (m *RepositoryManager) RenameThreshold() int {
+	return int(atomic.LoadInt32(&m.renameThreshold))
+}
+
+func (m *RepositoryManager) GraphOption() GraphOption {
+	return m.graphOption
+}
+
+func (m *RepositoryManager) SetRenameThreshold(threshold int) error {
+	if threshold < 1 || 100 < threshold {
+		return fmt.Errorf("rename threshold must be between 1 and 100: %d", threshold)
+	}
+	atomic.StoreInt32(&m.renameThreshold, int32(threshold))
+	return nil
+}
+
+// CombinedDiffParent can be passed to PatchFileDetails instead of a parent index
+// to get the combined diff of a merge commit against all of its parents.
+const CombinedDiffParent = -1
+
+// PatchFileDetails lists the changed files without their line stats, which CalculateLineStats calculates later.
+// It runs in the background, so the objects are read through another handle than m's.
+func (m *RepositoryManager) PatchFileDetails(target *gogigu.Node, parent int) ([]*PatchFileDetail, error) {
+	src, err := git.PlainOpen(m.path)
+	if err != nil {
+		return nil, err
+	}
+	if target.IsWorktree() {
+		return worktreeFileDetails(src)
 	}
-	nt, err := target.Commit.Tree()
+	c, err := src.CommitObject(target.Commit.Hash)
 	if err != nil {
 		return nil, err
 	}
-	pt, err := ps[0].Commit.Tree()
+	// the parents are read from the commit, since they may be out of the revisions shown
+	ps, err := parentCommits(srcCommits(src), c)
 	if err != nil {
 		return nil, err
 	}
-	changes, err := pt.Diff(nt)
+	if len(ps) == 0 {
+		return []*PatchFileDetail{}, nil
+	}
+	if parent == CombinedDiffParent {
+		return m.combinedPatchFileDetails(c, ps)
+	}
+	if parent < 0 || parent >= len(ps) {
+		return nil, fmt.Errorf("invalid parent index: %d", parent)
+	}
+	return m.patchFileDetailsFrom(ps[parent], c)
+}
+
+// parentCommits returns all the parents of the commit which the repository has, whether or not they are in the graph.
+func parentCommits(commit commitLookup, c *object.Commit) ([]*object.Commit, error) {
+	ps := make([]*object.Commit, 0, len(c.ParentHashes))
+	for _, h := range c.ParentHashes {
+		p, err := commit(h)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			ps = append(ps, p)
+		}
+	}
+	return ps, nil
+}
+
+func (m *RepositoryManager) patchFileDetailsFrom(from, to *object.Commit) ([]*PatchFileDetail, error) {
+	changes, err := m.treeChanges(from, to)
 	if err != nil {
 		return nil, err
 	}
+	source := &patchSource{}
 	ds := make([]*PatchFileDetail, 0)
 	for _, change := range changes {
-		changeType, err := changeTypeFrom(change)
+		ds = append(ds, newPatchFileDetail(change, source))
+	}
+	return ds, nil
+}
+
+// combinedPatchFileDetails lists only the files which differ from every parent, like `git show --cc`.
+func (m *RepositoryManager) combinedPatchFileDetails(target *object.Commit, ps []*object.Commit) ([]*PatchFileDetail, error) {
+	parentChanges := make([]map[string]*detectedChange, len(ps))
+	names := make([]string, 0)
+	for i, p := range ps {
+		changes, err := m.treeChanges(p, target)
 		if err != nil {
 			return nil, err
 		}
-		d := &PatchFileDetail{
-			name:       nameFrom(change, changeType),
-			changeType: changeType,
+		parentChanges[i] = make(map[string]*detectedChange)
+		for _, change := range changes {
+			name := nameFrom(change.Change, change.changeType)
+			parentChanges[i][name] = change
+			if i == 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	source := &patchSource{}
+	ds := make([]*PatchFileDetail, 0)
+	for _, name := range names {
+		changes := make([]*object.Change, len(ps))
+		for i := range ps {
+			if c, ok := parentChanges[i][name]; ok {
+				changes[i] = c.Change
+			}
 		}
+		if !changedFromAll(changes) {
+			continue
+		}
+		d := newPatchFileDetail(parentChanges[0][name], source)
+		d.parentChanges = changes
 		ds = append(ds, d)
 	}
 	return ds, nil
 }
 
+func changedFromAll(changes []*object.Change) bool {
+	for _, c := range changes {
+		if c == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *RepositoryManager) treeChanges(from, to *object.Commit) ([]*detectedChange, error) {
+	ft, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	tt, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(ft, tt)
+	if err != nil {
+		return nil, err
+	}
+	return detectRenames(changes, m.RenameThreshold())
+}
+
+func newPatchFileDetail(change *detectedChange, source *patchSource) *PatchFileDetail {
+	return &PatchFileDetail{
+		name:       nameFrom(change.Change, change.changeType),
+		oldName:    oldNameFrom(change.Change, change.changeType),
+		changeType: change.changeType,
+		similarity: change.similarity,
+		change:     change.Change,
+		source:     source,
+	}
+}
+
 func changeTypeFrom(change *object.Change) (ChangeType, error) {
 	a, err := change.Action()
 	if err != nil {
@@ -329,6 +819,13 @@ func nameFrom(c *object.Change, ct ChangeType) string {
 	return c.To.Name
 }
 
+func oldNameFrom(c *object.Change, ct ChangeType) string {
+	if ct == Insert {
+		return c.To.Name
+	}
+	return c.From.Name
+}
+
 func (m *RepositoryManager) RepositoryName() string {
 	if m == nil {
 		return ""
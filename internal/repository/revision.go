@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	errNoRevisionsToShow = errors.New("no revisions to show, only excluded ones")
+)
+
+// resolveRevisions returns the commits to show and the commits to exclude with their ancestors.
+// revisions are separated by spaces, and each of them is a name like `main`, an exclusion like `^main`,
+// a range like `main..feature`, or a symmetric difference like `main...feature`.
+// An empty side of a range means HEAD, like git.
+func (m *RepositoryManager) resolveRevisions(revisions string) ([]plumbing.Hash, []plumbing.Hash, error) {
+	include := make([]plumbing.Hash, 0)
+	exclude := make([]plumbing.Hash, 0)
+	for _, r := range strings.Fields(revisions) {
+		switch {
+		case strings.Contains(r, "..."):
+			a, b, err := m.resolveRange(r, "...")
+			if err != nil {
+				return nil, nil, err
+			}
+			bases, err := mergeBases(m.src, a, b)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, a, b)
+			exclude = append(exclude, bases...)
+		case strings.Contains(r, ".."):
+			a, b, err := m.resolveRange(r, "..")
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, b)
+			exclude = append(exclude, a)
+		case strings.HasPrefix(r, "^"):
+			h, err := resolveRevision(m.src, r[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			exclude = append(exclude, h)
+		default:
+			h, err := resolveRevision(m.src, r)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, h)
+		}
+	}
+	if len(include) == 0 {
+		return nil, nil, errNoRevisionsToShow
+	}
+	return include, exclude, nil
+}
+
+func (m *RepositoryManager) resolveRange(r, sep string) (plumbing.Hash, plumbing.Hash, error) {
+	sides := strings.SplitN(r, sep, 2)
+	a, err := resolveRevision(m.src, sides[0])
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.ZeroHash, err
+	}
+	b, err := resolveRevision(m.src, sides[1])
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.ZeroHash, err
+	}
+	return a, b, nil
+}
+
+func resolveRevision(src *git.Repository, name string) (plumbing.Hash, error) {
+	if name == "" {
+		name = "HEAD"
+	}
+	h, err := src.ResolveRevision(plumbing.Revision(name))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unknown revision: %s", name)
+	}
+	return *h, nil
+}
+
+func mergeBases(src *git.Repository, a, b plumbing.Hash) ([]plumbing.Hash, error) {
+	ca, err := src.CommitObject(a)
+	if err != nil {
+		return nil, err
+	}
+	cb, err := src.CommitObject(b)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := ca.MergeBase(cb)
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]plumbing.Hash, len(bases))
+	for i, c := range bases {
+		hs[i] = c.Hash
+	}
+	return hs, nil
+}
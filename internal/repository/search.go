@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+const (
+	minHashPrefixLength = 4
+)
+
+var (
+	hashPrefixPattern = regexp.MustCompile(`^[0-9a-f]+$`)
+)
+
+type SearchQuery struct {
+	// Text is matched against the message, the author and committer names and emails,
+	// and the hash prefix. It is case-insensitive, and ^ and $ of Regexp match at each line.
+	Text   string
+	Regexp bool
+
+	// Since and Until limit the author date of the commits. Zero values mean no limits.
+	Since time.Time
+	Until time.Time
+}
+
+// Search returns the commits which match the query, in the order of m.Nodes.
+// The whole history is read if it is streamed.
+func (m *RepositoryManager) Search(q *SearchQuery) (gogigu.Nodes, error) {
+	match, err := q.textMatcher()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := m.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(gogigu.Nodes, 0)
+	for _, n := range nodes {
+		if n.IsWorktree() {
+			continue
+		}
+		when := n.Commit.Author.When
+		if !q.Since.IsZero() && when.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !when.Before(q.Until) {
+			continue
+		}
+		if match(n) {
+			ret = append(ret, n)
+		}
+	}
+	return ret, nil
+}
+
+func (q *SearchQuery) textMatcher() (func(*gogigu.Node) bool, error) {
+	if q.Text == "" {
+		return func(*gogigu.Node) bool { return true }, nil
+	}
+	var contains func(string) bool
+	if q.Regexp {
+		re, err := regexp.Compile("(?im)" + q.Text)
+		if err != nil {
+			return nil, err
+		}
+		contains = re.MatchString
+	} else {
+		text := strings.ToLower(q.Text)
+		contains = func(s string) bool {
+			return strings.Contains(strings.ToLower(s), text)
+		}
+	}
+	hashPrefix := strings.ToLower(q.Text)
+	isHashPrefix := len(hashPrefix) >= minHashPrefixLength && hashPrefixPattern.MatchString(hashPrefix)
+	return func(n *gogigu.Node) bool {
+		if isHashPrefix && strings.HasPrefix(n.Hash(), hashPrefix) {
+			return true
+		}
+		c := n.Commit
+		return contains(c.Message) ||
+			contains(c.Author.Name) || contains(c.Author.Email) ||
+			contains(c.Committer.Name) || contains(c.Committer.Email)
+	}, nil
+}
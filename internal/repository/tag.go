@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// CreateTag creates a tag which points to the commit of hash.
+// The tag is annotated with the tagger from the git config if message is not empty, and lightweight otherwise.
+func (m *RepositoryManager) CreateTag(name, hash, message string) error {
+	if err := ValidateRefName(name); err != nil {
+		return err
+	}
+	if m.tagRef(name) != nil {
+		return fmt.Errorf("a tag named %q already exists", name)
+	}
+	var opts *git.CreateTagOptions
+	if strings.TrimSpace(message) != "" {
+		tagger, err := m.signature()
+		if err != nil {
+			return err
+		}
+		opts = &git.CreateTagOptions{Tagger: tagger, Message: message}
+	}
+	ref, err := m.src.CreateTag(name, plumbing.NewHash(hash), opts)
+	if err != nil {
+		return err
+	}
+	tag := &Ref{
+		refType:    Tag,
+		name:       name,
+		fullName:   ref.Name().String(),
+		targetHash: hash,
+	}
+	if opts != nil {
+		at, err := m.src.TagObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		tag.tag = at
+	}
+	m.tagsMap[hash] = append(m.tagsMap[hash], tag)
+	return nil
+}
+
+// DeleteTag deletes the tag ref. The tag object of an annotated tag is left to the garbage collection like git.
+func (m *RepositoryManager) DeleteTag(name string) error {
+	t := m.tagRef(name)
+	if t == nil {
+		return fmt.Errorf("tag not found: %s", name)
+	}
+	if err := m.src.DeleteTag(name); err != nil {
+		return err
+	}
+	ts := m.tagsMap[t.targetHash]
+	for i, r := range ts {
+		if r == t {
+			m.tagsMap[t.targetHash] = append(ts[:i:i], ts[i+1:]...)
+			break
+		}
+	}
+	if len(m.tagsMap[t.targetHash]) == 0 {
+		delete(m.tagsMap, t.targetHash)
+	}
+	return nil
+}
+
+// AnnotatedTagsAt returns the annotated tags which point to the commit.
+func (m *RepositoryManager) AnnotatedTagsAt(hash string) []*Ref {
+	ret := make([]*Ref, 0)
+	for _, t := range m.tagsMap[hash] {
+		if t.IsAnnotated() {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func (m *RepositoryManager) tagRef(name string) *Ref {
+	return fromRefNameFrom(m.tagsMap, name)
+}
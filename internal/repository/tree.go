@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"path"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+type TreeEntry struct {
+	path  string
+	name  string
+	mode  filemode.FileMode
+	size  int64
+	isDir bool
+}
+
+func (e *TreeEntry) Path() string {
+	return e.path
+}
+
+func (e *TreeEntry) Name() string {
+	return e.name
+}
+
+func (e *TreeEntry) Mode() filemode.FileMode {
+	return e.mode
+}
+
+// Size returns 0 for directories and submodules.
+func (e *TreeEntry) Size() int64 {
+	return e.size
+}
+
+func (e *TreeEntry) IsDir() bool {
+	return e.isDir
+}
+
+// TreeEntries returns the entries directly under dir, directories first.
+// dir must be a slash-separated path from the root of the tree, or an empty string for the root.
+func (m *RepositoryManager) TreeEntries(target *gogigu.Node, dir string) ([]*TreeEntry, error) {
+	t, err := target.Commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		t, err = t.Tree(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	entries := make([]*TreeEntry, 0, len(t.Entries))
+	for _, e := range t.Entries {
+		te := &TreeEntry{
+			path:  path.Join(dir, e.Name),
+			name:  e.Name,
+			mode:  e.Mode,
+			isDir: e.Mode == filemode.Dir,
+		}
+		if e.Mode.IsFile() {
+			size, err := t.Size(e.Name)
+			if err != nil {
+				return nil, err
+			}
+			te.size = size
+		}
+		entries = append(entries, te)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].isDir && !entries[j].isDir
+	})
+	return entries, nil
+}
+
+// FileContent returns the content of the file at the commit, or true if the file is binary.
+func (m *RepositoryManager) FileContent(target *gogigu.Node, filePath string) (string, bool, error) {
+	return commitFileContent(target.Commit, filePath)
+}
+
+func commitFileContent(c *object.Commit, filePath string) (string, bool, error) {
+	f, err := c.File(filePath)
+	if err != nil {
+		return "", false, err
+	}
+	return fileContent(f)
+}
+
+func FileLines(content string) []string {
+	return splitLines(content)
+}
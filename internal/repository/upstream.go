@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"container/heap"
+	"context"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/lusingander/fynegit/internal/gogigu"
+)
+
+type upstream struct {
+	name   string
+	gone   bool
+	ahead  int
+	behind int
+}
+
+// Upstream returns the short name of the branch which the local branch tracks, or an empty string if it tracks nothing.
+func (r *Ref) Upstream() string {
+	if r.upstream == nil {
+		return ""
+	}
+	return r.upstream.name
+}
+
+// IsUpstreamGone returns whether the branch tracks an upstream which no longer exists, like `[gone]` of `git branch -vv`.
+func (r *Ref) IsUpstreamGone() bool {
+	return r.upstream != nil && r.upstream.gone
+}
+
+// AheadBehind returns the numbers of the commits only on the branch and only on its upstream.
+func (r *Ref) AheadBehind() (int, int) {
+	if r.upstream == nil {
+		return 0, 0
+	}
+	return r.upstream.ahead, r.upstream.behind
+}
+
+// trackUpstreams reads `branch.<name>.remote` and `branch.<name>.merge`, and counts the commits ahead and behind.
+func trackUpstreams(ctx context.Context, src *git.Repository, repo *gogigu.Repository, branches map[string][]*Ref) error {
+	cfg, err := src.Config()
+	if err != nil {
+		return err
+	}
+	commit := graphCommits(src, repo)
+	for _, bs := range branches {
+		for _, b := range bs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			c, ok := cfg.Branches[b.name]
+			if !ok || c.Remote == "" || c.Merge == "" {
+				continue
+			}
+			u, err := readUpstream(src, commit, plumbing.NewHash(b.targetHash), c.Remote, c.Merge)
+			if err != nil {
+				return err
+			}
+			b.upstream = u
+		}
+	}
+	return nil
+}
+
+func readUpstream(src *git.Repository, commit commitLookup, local plumbing.Hash, remote string, merge plumbing.ReferenceName) (*upstream, error) {
+	refName := merge
+	if remote != "." {
+		// "." means the branch tracks another local branch
+		refName = plumbing.NewRemoteReferenceName(remote, merge.Short())
+	}
+	u := &upstream{name: refName.Short()}
+	ref, err := src.Reference(refName, true)
+	if err == plumbing.ErrReferenceNotFound {
+		u.gone = true
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.ahead, u.behind, err = aheadBehind(commit, local, ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// commitLookup returns the commit of the hash, or nil if the repository lacks it.
+type commitLookup func(plumbing.Hash) (*object.Commit, error)
+
+// srcCommits reads the commits from src.
+func srcCommits(src *git.Repository) commitLookup {
+	return func(h plumbing.Hash) (*object.Commit, error) {
+		c, err := src.CommitObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			// shallow clones lack the parents of the oldest commits
+			return nil, nil
+		}
+		return c, err
+	}
+}
+
+// graphCommits looks up the commits in the nodes of repo, which have been read already, and reads the others from src.
+// The graph may lack some commits if it follows only the first parents or is filtered,
+// but the commits of its nodes always have all their parents.
+func graphCommits(src *git.Repository, repo *gogigu.Repository) commitLookup {
+	read := srcCommits(src)
+	return func(h plumbing.Hash) (*object.Commit, error) {
+		if n := repo.Node(h.String()); n != nil && !n.IsWorktree() {
+			return n.Commit, nil
+		}
+		return read(h)
+	}
+}
+
+const (
+	// aheadBehindSlop is the number of the commits walked after all the commits left are reachable from both.
+	aheadBehindSlop = 5
+)
+
+const (
+	leftSide uint8 = 1 << iota
+	rightSide
+	bothSides = leftSide | rightSide
+)
+
+// aheadBehind counts the commits reachable only from left and only from right, like `git rev-list --left-right --count left...right`.
+// The commits are walked from the newest like git, and the walk stops a few commits after all the commits left to walk are reachable from both
+// and older than the last one reachable from one side, so it reads only the commits around the merge bases unless the commit times are skewed.
+func aheadBehind(commit commitLookup, left, right plumbing.Hash) (int, int, error) {
+	if left == right {
+		return 0, 0, nil
+	}
+	read := make(map[plumbing.Hash]*object.Commit)
+	sides := make(map[plumbing.Hash]uint8)
+	queued := make(map[plumbing.Hash]bool)
+	q := &commitQueue{}
+	// oneSide is the number of the queued commits which are not reachable from both yet
+	oneSide := 0
+	// mark passes the side to the ancestors walked already at once, like mark_parents_uninteresting of git
+	mark := func(h plumbing.Hash, side uint8) error {
+		stack := []plumbing.Hash{h}
+		for len(stack) > 0 {
+			h := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			old := sides[h]
+			if old|side == old {
+				continue
+			}
+			c, ok := read[h]
+			if !ok {
+				c, err := commit(h)
+				if err != nil {
+					return err
+				}
+				if c == nil {
+					continue
+				}
+				read[h] = c
+				sides[h] = side
+				queued[h] = true
+				if side != bothSides {
+					oneSide++
+				}
+				heap.Push(q, c)
+				continue
+			}
+			sides[h] = old | side
+			if queued[h] {
+				// its parents get the side when it is popped
+				if sides[h] == bothSides {
+					oneSide--
+				}
+				continue
+			}
+			stack = append(stack, c.ParentHashes...)
+		}
+		return nil
+	}
+	if err := mark(left, leftSide); err != nil {
+		return 0, 0, err
+	}
+	if err := mark(right, rightSide); err != nil {
+		return 0, 0, err
+	}
+	var last time.Time
+	slop := aheadBehindSlop
+	for q.Len() > 0 {
+		c := heap.Pop(q).(*object.Commit)
+		queued[c.Hash] = false
+		side := sides[c.Hash]
+		if side != bothSides {
+			oneSide--
+			last = c.Committer.When
+		}
+		for _, p := range c.ParentHashes {
+			if err := mark(p, side); err != nil {
+				return 0, 0, err
+			}
+		}
+		if side != bothSides {
+			continue
+		}
+		// like still_interesting of git, a commit newer than the last one-sided one may still pass a side to the ones walked already
+		if oneSide > 0 || (q.Len() > 0 && !(*q)[0].Committer.When.Before(last)) {
+			slop = aheadBehindSlop
+		} else if slop--; slop == 0 {
+			break
+		}
+	}
+	ahead, behind := 0, 0
+	for _, side := range sides {
+		switch side {
+		case leftSide:
+			ahead++
+		case rightSide:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// commitQueue pops the newest commit first.
+type commitQueue []*object.Commit
+
+func (q commitQueue) Len() int {
+	return len(q)
+}
+
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+
+func (q commitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *commitQueue) Push(x interface{}) {
+	*q = append(*q, x.(*object.Commit))
+}
+
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*q = old[0 : n-1]
+	return x
+}
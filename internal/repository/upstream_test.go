@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testCommit is a commit of a synthetic history, whose time is in seconds.
+// A parent which is not in the history is missing like in a shallow clone.
+type testCommit struct {
+	name    string
+	parents []string
+	when    int
+}
+
+func testHash(name string) plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.CommitObject, []byte(name))
+}
+
+func testCommitLookup(commits []testCommit) commitLookup {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cs := make(map[plumbing.Hash]*object.Commit)
+	for _, c := range commits {
+		parents := make([]plumbing.Hash, len(c.parents))
+		for i, p := range c.parents {
+			parents[i] = testHash(p)
+		}
+		h := testHash(c.name)
+		cs[h] = &object.Commit{
+			Hash:         h,
+			Committer:    object.Signature{When: base.Add(time.Duration(c.when) * time.Second)},
+			ParentHashes: parents,
+		}
+	}
+	return func(h plumbing.Hash) (*object.Commit, error) {
+		return cs[h], nil
+	}
+}
+
+func TestAheadBehind(t *testing.T) {
+	tests := []struct {
+		name        string
+		commits     []testCommit
+		left, right string
+		ahead       int
+		behind      int
+	}{
+		{
+			name: "diverged",
+			commits: []testCommit{
+				{name: "a", when: 1},
+				{name: "b", parents: []string{"a"}, when: 2},
+				{name: "l1", parents: []string{"b"}, when: 3},
+				{name: "r1", parents: []string{"b"}, when: 4},
+				{name: "l2", parents: []string{"l1"}, when: 5},
+			},
+			left:   "l2",
+			right:  "r1",
+			ahead:  2,
+			behind: 1,
+		},
+		{
+			name: "ancestor",
+			commits: []testCommit{
+				{name: "a", when: 1},
+				{name: "b", parents: []string{"a"}, when: 2},
+				{name: "c", parents: []string{"b"}, when: 3},
+			},
+			left:   "a",
+			right:  "c",
+			ahead:  0,
+			behind: 2,
+		},
+		{
+			name: "descendant",
+			commits: []testCommit{
+				{name: "a", when: 1},
+				{name: "b", parents: []string{"a"}, when: 2},
+				{name: "c", parents: []string{"b"}, when: 3},
+			},
+			left:   "c",
+			right:  "a",
+			ahead:  2,
+			behind: 0,
+		},
+		{
+			name: "equal",
+			commits: []testCommit{
+				{name: "a", when: 1},
+				{name: "b", parents: []string{"a"}, when: 2},
+			},
+			left:   "b",
+			right:  "b",
+			ahead:  0,
+			behind: 0,
+		},
+		{
+			name: "merged",
+			commits: []testCommit{
+				{name: "a", when: 1},
+				{name: "l1", parents: []string{"a"}, when: 2},
+				{name: "r1", parents: []string{"a"}, when: 3},
+				{name: "l2", parents: []string{"l1", "r1"}, when: 4},
+				{name: "r2", parents: []string{"r1"}, when: 5},
+			},
+			left:   "l2",
+			right:  "r2",
+			ahead:  2,
+			behind: 1,
+		},
+		{
+			name: "skewed base",
+			// the merge base is newer than the commits after it
+			commits: []testCommit{
+				{name: "a", when: 1},
+				{name: "b", parents: []string{"a"}, when: 100},
+				{name: "l1", parents: []string{"b"}, when: 2},
+				{name: "r1", parents: []string{"b"}, when: 3},
+			},
+			left:   "l1",
+			right:  "r1",
+			ahead:  1,
+			behind: 1,
+		},
+		{
+			name: "skewed merge parent",
+			// w is popped after v, though it passes the right side to v, which is the last one-sided commit
+			commits: []testCommit{
+				{name: "v", when: 10},
+				{name: "w", parents: []string{"v"}, when: 2},
+				{name: "l", parents: []string{"v", "w"}, when: 20},
+				{name: "r", parents: []string{"w"}, when: 19},
+			},
+			left:   "l",
+			right:  "r",
+			ahead:  1,
+			behind: 1,
+		},
+		{
+			name: "shallow",
+			commits: []testCommit{
+				{name: "b", parents: []string{"a"}, when: 2},
+				{name: "l1", parents: []string{"b"}, when: 3},
+				{name: "r1", parents: []string{"b"}, when: 4},
+			},
+			left:   "l1",
+			right:  "r1",
+			ahead:  1,
+			behind: 1,
+		},
+		{
+			name: "shallow without merge base",
+			commits: []testCommit{
+				{name: "l1", parents: []string{"a"}, when: 3},
+				{name: "l2", parents: []string{"l1"}, when: 4},
+				{name: "r1", parents: []string{"b"}, when: 5},
+			},
+			left:   "l2",
+			right:  "r1",
+			ahead:  2,
+			behind: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ahead, behind, err := aheadBehind(testCommitLookup(tt.commits), testHash(tt.left), testHash(tt.right))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ahead != tt.ahead || behind != tt.behind {
+				t.Errorf("aheadBehind() = (%d, %d), want (%d, %d)", ahead, behind, tt.ahead, tt.behind)
+			}
+		})
+	}
+}
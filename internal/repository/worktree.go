@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/binary"
+)
+
+var (
+	errMissingIdentity    = errors.New("user.name and user.email must be set in git config")
+	errEmptyCommitMessage = errors.New("commit message must not be empty")
+	errNothingToAmend     = errors.New("there is no commit to amend")
+)
+
+type fileContents struct {
+	from   string
+	to     string
+	binary bool
+}
+
+// hasUncommittedChanges returns false for bare repositories.
+func hasUncommittedChanges(src *git.Repository) (bool, error) {
+	w, err := src.Worktree()
+	if err == git.ErrIsBareRepository {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return false, err
+	}
+	return !status.IsClean(), nil
+}
+
+// worktreeFileDetails returns the files which differ between HEAD and the worktree, including untracked files.
+// Both staged and unstaged changes are included, like `git diff HEAD`.
+func worktreeFileDetails(src *git.Repository) ([]*PatchFileDetail, error) {
+	w, err := src.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	head, err := headTree(src)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(status))
+	for p := range status {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	ds := make([]*PatchFileDetail, 0, len(paths))
+	for _, p := range paths {
+		from, fromBinary, inHead, err := headFileContent(head, p)
+		if err != nil {
+			return nil, err
+		}
+		to, toBinary, inWorktree, err := worktreeFileContent(w, p)
+		if err != nil {
+			return nil, err
+		}
+		d := &PatchFileDetail{
+			name:      p,
+			oldName:   p,
+			untracked: status[p].Staging == git.Untracked,
+			staged:    isStaged(status[p]),
+			unstaged:  isUnstaged(status[p]),
+			contents: &fileContents{
+				from:   from,
+				to:     to,
+				binary: fromBinary || toBinary,
+			},
+		}
+		switch {
+		case inHead && inWorktree:
+			d.changeType = Modify
+		case inWorktree:
+			d.changeType = Insert
+		case inHead:
+			d.changeType = Delete
+		default:
+			// added to the index and then removed from the worktree
+			continue
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+func isStaged(s *git.FileStatus) bool {
+	return s.Staging != git.Unmodified && s.Staging != git.Untracked
+}
+
+func isUnstaged(s *git.FileStatus) bool {
+	return s.Worktree != git.Unmodified
+}
+
+// headFileContent returns the content of the file, whether it is binary, and whether it exists.
+func headFileContent(head *object.Tree, path string) (string, bool, bool, error) {
+	if head == nil {
+		return "", false, false, nil
+	}
+	f, err := head.File(path)
+	if err == object.ErrFileNotFound {
+		return "", false, false, nil
+	}
+	if err != nil {
+		return "", false, false, err
+	}
+	c, binary, err := fileContent(f)
+	if err != nil {
+		return "", false, false, err
+	}
+	return c, binary, true, nil
+}
+
+// worktreeFileContent returns the content of the file, whether it is binary, and whether it exists.
+func worktreeFileContent(w *git.Worktree, path string) (string, bool, bool, error) {
+	if _, err := w.Filesystem.Lstat(path); os.IsNotExist(err) {
+		return "", false, false, nil
+	} else if err != nil {
+		return "", false, false, err
+	}
+	f, err := w.Filesystem.Open(path)
+	if err != nil {
+		return "", false, false, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", false, false, err
+	}
+	isBinary, err := binary.IsBinary(bytes.NewReader(b))
+	if err != nil {
+		return "", false, false, err
+	}
+	if isBinary {
+		return "", true, true, nil
+	}
+	return string(b), false, true, nil
+}
+
+// Stage adds the whole file to the index, or removes it from the index if it is deleted in the worktree.
+func (m *RepositoryManager) Stage(path string) error {
+	w, err := m.src.Worktree()
+	if err != nil {
+		return err
+	}
+	_, err = w.Add(path)
+	return err
+}
+
+// Unstage resets the index entry of the file to HEAD, like `git reset -- <path>`.
+func (m *RepositoryManager) Unstage(path string) error {
+	head, err := headTree(m.src)
+	if err != nil {
+		return err
+	}
+	idx, err := m.src.Storer.Index()
+	if err != nil {
+		return err
+	}
+	var entry *object.TreeEntry
+	if head != nil {
+		entry, err = head.FindEntry(path)
+		if err != nil && err != object.ErrEntryNotFound && err != object.ErrDirectoryNotFound {
+			return err
+		}
+	}
+	if entry == nil {
+		if _, err := idx.Remove(path); err != nil && err != index.ErrEntryNotFound {
+			return err
+		}
+		return m.src.Storer.SetIndex(idx)
+	}
+	e, err := idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		e = idx.Add(path)
+	} else if err != nil {
+		return err
+	}
+	size, err := head.Size(path)
+	if err != nil {
+		return err
+	}
+	e.Hash = entry.Hash
+	e.Mode = entry.Mode
+	e.Size = uint32(size)
+	return m.src.Storer.SetIndex(idx)
+}
+
+// headTree returns nil if HEAD does not point to any commit yet.
+func headTree(src *git.Repository) (*object.Tree, error) {
+	c, err := headCommit(src)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return c.Tree()
+}
+
+func headCommit(src *git.Repository) (*object.Commit, error) {
+	head, err := src.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return src.CommitObject(head.Hash())
+}
+
+// HeadMessage returns the message of the commit which HEAD points to, or an empty string if there is no commit yet.
+func (m *RepositoryManager) HeadMessage() (string, error) {
+	c, err := headCommit(m.src)
+	if err != nil || c == nil {
+		return "", err
+	}
+	return c.Message, nil
+}
+
+// Identity returns the user name and email from the git config, merging the system, global and local scopes.
+func (m *RepositoryManager) Identity() (string, string, error) {
+	cfg, err := m.src.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return "", "", err
+	}
+	if cfg.User.Name == "" || cfg.User.Email == "" {
+		return "", "", errMissingIdentity
+	}
+	return cfg.User.Name, cfg.User.Email, nil
+}
+
+// signature returns the identity from the git config with the current time.
+func (m *RepositoryManager) signature() (*object.Signature, error) {
+	name, email, err := m.Identity()
+	if err != nil {
+		return nil, err
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+// Commit creates a commit from the index and moves HEAD to it.
+// If amend is true, the commit replaces the commit of HEAD, keeping its author and parents.
+// The caller must reopen the repository to show the new commit in the graph.
+func (m *RepositoryManager) Commit(message string, amend bool) error {
+	if strings.TrimSpace(message) == "" {
+		return errEmptyCommitMessage
+	}
+	committer, err := m.signature()
+	if err != nil {
+		return err
+	}
+	opts := &git.CommitOptions{Author: committer, Committer: committer}
+	amendRoot := false
+	if amend {
+		head, err := headCommit(m.src)
+		if err != nil {
+			return err
+		}
+		if head == nil {
+			return errNothingToAmend
+		}
+		author := head.Author
+		opts.Author = &author
+		opts.Parents = head.ParentHashes
+		// go-git regards empty parents as HEAD, so the parents are dropped after the commit
+		amendRoot = len(head.ParentHashes) == 0
+	}
+	w, err := m.src.Worktree()
+	if err != nil {
+		return err
+	}
+	hash, err := w.Commit(message, opts)
+	if err != nil {
+		return err
+	}
+	if amendRoot {
+		return m.replaceHeadWithRoot(hash)
+	}
+	return nil
+}
+
+// replaceHeadWithRoot moves HEAD, or the branch which HEAD points to, to a copy of the commit without parents.
+// The commit itself is left to the garbage collection like the commits replaced by amend.
+func (m *RepositoryManager) replaceHeadWithRoot(hash plumbing.Hash) error {
+	c, err := m.src.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	root := &object.Commit{
+		Author:    c.Author,
+		Committer: c.Committer,
+		Message:   c.Message,
+		TreeHash:  c.TreeHash,
+	}
+	obj := m.src.Storer.NewEncodedObject()
+	if err := root.Encode(obj); err != nil {
+		return err
+	}
+	rootHash, err := m.src.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+	head, err := m.src.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+	name := plumbing.HEAD
+	if head.Type() == plumbing.SymbolicReference {
+		name = head.Target()
+	}
+	return m.src.Storer.SetReference(plumbing.NewHashReference(name, rootHash))
+}
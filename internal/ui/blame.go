@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+const (
+	blameAuthorColumnWidth = 16
+)
+
+var (
+	blameWindowSize = fyne.NewSize(1000, 800)
+)
+
+func (m *manager) showBlame(n *gogigu.Node, path string) {
+	lines, err := m.rm.Blame(n, path)
+	if err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	w := fyne.CurrentApp().NewWindow(fmt.Sprintf("Blame %s @ %s - %s", path, n.ShortHash(), appName))
+	w.SetContent(m.buildBlameContent(lines))
+	w.Resize(blameWindowSize)
+	w.Show()
+}
+
+func (m *manager) buildBlameContent(lines []*repository.BlameLine) fyne.CanvasObject {
+	return widget.NewList(
+		func() int {
+			return len(lines)
+		},
+		func() fyne.CanvasObject {
+			gutter := newTappableLabel(monospaceTextStyle)
+			lineNo := widget.NewLabelWithStyle("", fyne.TextAlignTrailing, monospaceTextStyle)
+			content := widget.NewLabelWithStyle("", fyne.TextAlignLeading, monospaceTextStyle)
+			return container.NewHBox(gutter, lineNo, content)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			l := lines[id]
+			objs := item.(*fyne.Container).Objects
+			gutter := objs[0].(*tappableLabel)
+			gutter.SetText(blameGutterText(lines, id))
+			gutter.onTapped = func() {
+				m.selectCommit(l.Hash())
+			}
+			objs[1].(*widget.Label).SetText(fmt.Sprintf("%5d", l.LineNo()))
+			objs[2].(*widget.Label).SetText(expandTabs(l.Content()))
+		},
+	)
+}
+
+// blameGutterText is blank if the line is changed by the same commit as the previous line.
+func blameGutterText(lines []*repository.BlameLine, id int) string {
+	l := lines[id]
+	author := []rune(l.Author())
+	if len(author) > blameAuthorColumnWidth {
+		author = append(author[:blameAuthorColumnWidth-1], '…')
+	}
+	text := fmt.Sprintf("%s %s %-*s", l.ShortHash(), l.When().Format(dateFormat), blameAuthorColumnWidth, string(author))
+	if id > 0 && lines[id-1].Hash() == l.Hash() {
+		return strings.Repeat(" ", len([]rune(text)))
+	}
+	return text
+}
+
+func (m *manager) selectCommit(hash string) {
+	if m.commitGraphView == nil {
+		return
+	}
+	// the commit may not be read yet if the history is streamed
+	n := m.rm.Lookup(hash)
+	if n == nil {
+		return
+	}
+	m.commitGraphView.List.Select(n.PosY())
+}
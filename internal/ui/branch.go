@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+// commitContextMenu returns nil for the worktree, which cannot be a target of refs.
+func (m *manager) commitContextMenu(n *gogigu.Node) *fyne.Menu {
+	if n.IsWorktree() {
+		return nil
+	}
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Create branch here...", func() {
+			m.showCreateBranchDialog(n)
+		}),
+		fyne.NewMenuItem("Create tag here...", func() {
+			m.showCreateTagDialog(n)
+		}),
+		fyne.NewMenuItemSeparator(),
+	}
+	for _, b := range m.rm.LocalBranchesAt(n.Hash()) {
+		b := b
+		items = append(items, fyne.NewMenuItem(fmt.Sprintf("Checkout %s", b), func() {
+			m.checkout(func() error { return m.rm.CheckoutBranch(b) })
+		}))
+	}
+	items = append(items, fyne.NewMenuItem("Checkout (detached HEAD)", func() {
+		m.checkout(func() error { return m.rm.CheckoutCommit(n.Hash()) })
+	}))
+	items = append(items, fyne.NewMenuItemSeparator())
+	items = append(items, m.compareMenuItems(n.Hash())...)
+	return fyne.NewMenu("", items...)
+}
+
+func (m *manager) branchContextMenu(name string) *fyne.Menu {
+	checkoutMenuItem := fyne.NewMenuItem("Checkout", func() {
+		m.checkout(func() error { return m.rm.CheckoutBranch(name) })
+	})
+	checkoutMenuItem.Disabled = name == m.rm.CurrentBranch()
+	renameMenuItem := fyne.NewMenuItem("Rename...", func() {
+		m.showRenameBranchDialog(name)
+	})
+	deleteMenuItem := fyne.NewMenuItem("Delete...", func() {
+		m.confirmDeleteBranch(name)
+	})
+	deleteMenuItem.Disabled = name == m.rm.CurrentBranch()
+	return fyne.NewMenu("", checkoutMenuItem, renameMenuItem, fyne.NewMenuItemSeparator(), deleteMenuItem)
+}
+
+func (m *manager) showCreateBranchDialog(n *gogigu.Node) {
+	entry := widget.NewEntry()
+	entry.Validator = repository.ValidateRefName
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", entry),
+	}
+	callback := func(ok bool) {
+		if !ok {
+			return
+		}
+		m.updateRefs(m.rm.CreateBranch(entry.Text, n.Hash()))
+	}
+	title := fmt.Sprintf("Create branch at %s", n.ShortHash())
+	dialog.ShowForm(title, "Create", "Cancel", items, callback, m.Window)
+}
+
+func (m *manager) showRenameBranchDialog(name string) {
+	entry := widget.NewEntry()
+	entry.SetText(name)
+	entry.Validator = repository.ValidateRefName
+	items := []*widget.FormItem{
+		widget.NewFormItem("New name", entry),
+	}
+	callback := func(ok bool) {
+		if !ok || entry.Text == name {
+			return
+		}
+		m.updateRefs(m.rm.RenameBranch(name, entry.Text))
+	}
+	dialog.ShowForm(fmt.Sprintf("Rename branch %s", name), "Rename", "Cancel", items, callback, m.Window)
+}
+
+func (m *manager) confirmDeleteBranch(name string) {
+	msg := fmt.Sprintf("Delete branch %s?", name)
+	if !m.rm.IsMerged(name) {
+		msg = fmt.Sprintf("Branch %s is not merged into HEAD.\nCommits only on it may be lost.\nDelete anyway?", name)
+	}
+	callback := func(ok bool) {
+		if !ok {
+			return
+		}
+		m.updateRefs(m.rm.DeleteBranch(name))
+	}
+	dialog.ShowConfirm("Delete branch", msg, callback, m.Window)
+}
+
+// updateRefs refreshes the views showing refs after the refs are changed in place.
+func (m *manager) updateRefs(err error) {
+	if err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	m.refreshSideMenu()
+	m.commitGraphView.List.Refresh()
+	m.refreshCommitViews()
+}
+
+// checkout reloads the repository in the background since the graph must be rebuilt after checkout.
+func (m *manager) checkout(f func() error) {
+	if err := f(); err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	m.reloadAfterChange()
+}
+
+// refLabelText prefixes the name of a tag like `tag: v1.0` of `git log --decorate`,
+// and appends the tracking status to the name of a local branch, like `feature ↑3 ↓12`.
+func refLabelText(ref *repository.Ref) string {
+	if ref.RefType() == repository.Tag {
+		return fmt.Sprintf("tag: %s", ref.Name())
+	}
+	if t := trackingText(ref); t != "" {
+		return fmt.Sprintf("%s %s", ref.Name(), t)
+	}
+	return ref.Name()
+}
+
+// trackingText returns an empty string if the branch tracks nothing or is up to date with its upstream.
+func trackingText(ref *repository.Ref) string {
+	if ref.IsUpstreamGone() {
+		return "[gone]"
+	}
+	ahead, behind := ref.AheadBehind()
+	ss := make([]string, 0)
+	if ahead > 0 {
+		ss = append(ss, fmt.Sprintf("↑%d", ahead))
+	}
+	if behind > 0 {
+		ss = append(ss, fmt.Sprintf("↓%d", behind))
+	}
+	return strings.Join(ss, " ")
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"image/color"
 
+	"fyne.io/fyne/v2/theme"
 	"github.com/lusingander/fynegit/internal/repository"
 )
 
@@ -13,9 +14,24 @@ var (
 	refsBranchColorFg = color.NRGBA{0, 90, 0, 255}
 	refsRemoteColorBg = color.NRGBA{200, 150, 200, 150}
 	refsRemoteColorFg = color.NRGBA{70, 0, 70, 255}
+	refsHeadColorBg   = color.NRGBA{150, 190, 240, 150}
+	refsHeadColorFg   = color.NRGBA{0, 50, 130, 255}
 
 	refsNoticeColorBg = color.NRGBA{200, 200, 200, 200}
 	refsNoticeColorFg = color.NRGBA{100, 100, 100, 255}
+
+	diffAddedColorBg      = color.NRGBA{150, 220, 150, 100}
+	diffDeletedColorBg    = color.NRGBA{220, 150, 150, 100}
+	diffHunkHeaderColorBg = color.NRGBA{150, 180, 220, 100}
+	diffEmptyLineColorBg  = color.NRGBA{200, 200, 200, 80}
+
+	lineStatAddedColorFg   = color.NRGBA{40, 160, 60, 255}
+	lineStatDeletedColorFg = color.NRGBA{200, 40, 40, 255}
+	lineStatNeutralColorFg = color.NRGBA{200, 200, 200, 255}
+
+	subjectLengthOverColorFg = color.NRGBA{200, 40, 40, 255}
+
+	searchHighlightColorBg = color.NRGBA{255, 220, 100, 100}
 )
 
 func refsColor(t repository.RefType) (color.Color, color.Color) {
@@ -26,6 +42,8 @@ func refsColor(t repository.RefType) (color.Color, color.Color) {
 		return refsBranchColorBg, refsBranchColorFg
 	case repository.RemoteBranch:
 		return refsRemoteColorBg, refsRemoteColorFg
+	case repository.Head:
+		return refsHeadColorBg, refsHeadColorFg
 	}
 	return nil, nil
 }
@@ -33,3 +51,47 @@ func refsColor(t repository.RefType) (color.Color, color.Color) {
 func refsNoticeColor() (color.Color, color.Color) {
 	return refsNoticeColorBg, refsNoticeColorFg
 }
+
+func diffLineColor(t repository.DiffLineType) color.Color {
+	switch t {
+	case repository.DiffAdded:
+		return diffAddedColorBg
+	case repository.DiffDeleted:
+		return diffDeletedColorBg
+	}
+	return color.Transparent
+}
+
+func diffHunkHeaderColor() color.Color {
+	return diffHunkHeaderColorBg
+}
+
+func diffEmptyLineColor() color.Color {
+	return diffEmptyLineColorBg
+}
+
+func lineStatAddedColor() color.Color {
+	return lineStatAddedColorFg
+}
+
+func lineStatDeletedColor() color.Color {
+	return lineStatDeletedColorFg
+}
+
+func lineStatNeutralColor() color.Color {
+	return lineStatNeutralColorFg
+}
+
+func subjectLengthColor(over bool) color.Color {
+	if over {
+		return subjectLengthOverColorFg
+	}
+	return theme.ForegroundColor()
+}
+
+func searchHighlightColor(highlighted bool) color.Color {
+	if highlighted {
+		return searchHighlightColorBg
+	}
+	return color.Transparent
+}
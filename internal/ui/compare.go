@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+var (
+	compareWindowSize = fyne.NewSize(1400, 800)
+)
+
+// compareMenuItems lets rev, a hash or a ref name, be compared with the one selected before.
+func (m *manager) compareMenuItems(rev string) []*fyne.MenuItem {
+	base := m.compareBase
+	selectMenuItem := fyne.NewMenuItem("Select for compare", func() {
+		m.compareBase = rev
+	})
+	compareText := "Compare with selected"
+	if base != "" {
+		compareText = fmt.Sprintf("Compare with %s", revisionLabel(base))
+	}
+	compareMenuItem := fyne.NewMenuItem(compareText, func() {
+		m.showCompare(base, rev)
+	})
+	compareMenuItem.Disabled = base == "" || base == rev
+	return []*fyne.MenuItem{selectMenuItem, compareMenuItem}
+}
+
+// revisionLabel shortens full hashes, and leaves ref names as they are.
+func revisionLabel(rev string) string {
+	if len(rev) == len(gogigu.WorktreeHash) {
+		return rev[:7]
+	}
+	return rev
+}
+
+// showCompare compares the revisions in the background, and it is canceled when the window is closed.
+func (m *manager) showCompare(a, b string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	title := fmt.Sprintf("Compare %s...%s - %s", revisionLabel(a), revisionLabel(b), appName)
+	w := fyne.CurrentApp().NewWindow(title)
+	w.SetOnClosed(cancel)
+
+	phase := widget.NewLabel(comparePhaseText(repository.ComparingCommits, 0))
+	cancelButton := widget.NewButton("Cancel", func() {
+		cancel()
+		w.Close()
+	})
+	w.SetContent(container.NewCenter(container.NewVBox(
+		phase,
+		widget.NewProgressBarInfinite(),
+		cancelButton,
+	)))
+	w.Resize(compareWindowSize)
+	w.Show()
+
+	rm := m.rm
+	go func() {
+		c, err := rm.Compare(ctx, a, b, func(p repository.ComparePhase, commits int) {
+			phase.SetText(comparePhaseText(p, commits))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.SetContent(container.NewCenter(widget.NewLabel(err.Error())))
+			return
+		}
+		w.SetContent(m.buildCompareContent(rm, c))
+	}()
+}
+
+func comparePhaseText(p repository.ComparePhase, commits int) string {
+	switch p {
+	case repository.ComparingCommits:
+		return fmt.Sprintf("Reading commits... (%d)", commits)
+	case repository.ComparingFiles:
+		return "Comparing files..."
+	}
+	return ""
+}
+
+func (m *manager) buildCompareContent(rm *repository.RepositoryManager, c *repository.Comparison) fyne.CanvasObject {
+	dv := newDiffView()
+
+	commits := widget.NewList(
+		func() int {
+			return len(c.Nodes)
+		},
+		func() fyne.CanvasObject {
+			return commitGraphItem(c.Repository)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			updateCommitGraphItem(rm, c.Repository, c.Nodes[id], false, item)
+		},
+	)
+	commits.OnSelected = func(id widget.ListItemID) {
+		if m.rm != rm {
+			// the repository has been reopened
+			return
+		}
+		m.selectCommit(c.Nodes[id].Hash())
+	}
+
+	details := c.Details()
+	files := widget.NewList(
+		func() int {
+			return len(details)
+		},
+		func() fyne.CanvasObject {
+			return changeDetailLineItem()
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			updateChangeDetailLine(details[id], item)
+		},
+	)
+	files.OnSelected = func(id widget.ListItemID) {
+		dv.showFileDiff(rm, details[id])
+	}
+
+	summary := widget.NewLabel(fmt.Sprintf("%s → %s: %d commits, %s",
+		c.From().Hash.String()[:7], c.To().Hash.String()[:7], len(c.Nodes), changesSummary(details)))
+	vs := container.NewVSplit(commits, files)
+	vs.SetOffset(0.4)
+	hs := container.NewHSplit(vs, dv.Container)
+	hs.SetOffset(0.4)
+	return container.NewBorder(summary, nil, nil, nil, hs)
+}
@@ -0,0 +1,302 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+const (
+	diffTabWidth = 4
+
+	binaryFileDiffMessage = "Binary file differs"
+)
+
+var (
+	monospaceTextStyle = fyne.TextStyle{Monospace: true}
+)
+
+type diffViewMode int
+
+const (
+	unifiedDiffMode diffViewMode = iota
+	splitDiffMode
+)
+
+var (
+	diffViewModeNames = []string{"Unified", "Split"}
+)
+
+type diffView struct {
+	*fyne.Container
+
+	unifiedList *widget.List
+	splitList   *widget.List
+
+	mode        diffViewMode
+	unifiedRows []*diffRow
+	splitRows   []*splitDiffRow
+}
+
+type diffRow struct {
+	header string
+	line   *repository.DiffLine
+}
+
+// splitDiffRow has nil oldLine or newLine when the line exists only on the other side.
+type splitDiffRow struct {
+	header  string
+	oldLine *repository.DiffLine
+	newLine *repository.DiffLine
+}
+
+func (m *manager) buildDiffView() fyne.CanvasObject {
+	v := newDiffView()
+	m.diffView = v
+	return v.Container
+}
+
+func newDiffView() *diffView {
+	v := &diffView{
+		mode:        unifiedDiffMode,
+		unifiedRows: make([]*diffRow, 0),
+		splitRows:   make([]*splitDiffRow, 0),
+	}
+	v.unifiedList = widget.NewList(
+		func() int {
+			return len(v.unifiedRows)
+		},
+		func() fyne.CanvasObject {
+			return diffLineItem()
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			updateDiffLineItem(v.unifiedRows[id], item)
+		},
+	)
+	v.splitList = widget.NewList(
+		func() int {
+			return len(v.splitRows)
+		},
+		func() fyne.CanvasObject {
+			return splitDiffLineItem()
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			updateSplitDiffLineItem(v.splitRows[id], item)
+		},
+	)
+	v.splitList.Hide()
+
+	modeRadio := widget.NewRadioGroup(diffViewModeNames, func(s string) {
+		for i, name := range diffViewModeNames {
+			if name == s {
+				v.setMode(diffViewMode(i))
+			}
+		}
+	})
+	modeRadio.Horizontal = true
+	modeRadio.Required = true
+	modeRadio.SetSelected(diffViewModeNames[v.mode])
+
+	v.Container = container.NewBorder(
+		modeRadio, nil, nil, nil,
+		container.NewMax(v.unifiedList, v.splitList),
+	)
+	return v
+}
+
+func (v *diffView) setMode(mode diffViewMode) {
+	v.mode = mode
+	switch mode {
+	case unifiedDiffMode:
+		v.splitList.Hide()
+		v.unifiedList.Show()
+	case splitDiffMode:
+		v.unifiedList.Hide()
+		v.splitList.Show()
+	}
+}
+
+func diffLineItem() fyne.CanvasObject {
+	bg := canvas.NewRectangle(nil)
+	lineNo := widget.NewLabelWithStyle("", fyne.TextAlignLeading, monospaceTextStyle)
+	content := widget.NewLabelWithStyle("", fyne.TextAlignLeading, monospaceTextStyle)
+	return container.NewMax(bg, container.NewHBox(lineNo, content))
+}
+
+func updateDiffLineItem(row *diffRow, item fyne.CanvasObject) {
+	objs := item.(*fyne.Container).Objects
+	bg := objs[0].(*canvas.Rectangle)
+	labels := objs[1].(*fyne.Container).Objects
+	lineNo := labels[0].(*widget.Label)
+	content := labels[1].(*widget.Label)
+	if row.line == nil {
+		bg.FillColor = diffHunkHeaderColor()
+		bg.Refresh()
+		lineNo.SetText(formatDiffLineNo(0, 0))
+		content.SetText(row.header)
+		return
+	}
+	bg.FillColor = diffLineColor(row.line.LineType())
+	bg.Refresh()
+	lineNo.SetText(formatDiffLineNo(row.line.OldLineNo(), row.line.NewLineNo()))
+	content.SetText(diffLinePrefix(row.line) + expandTabs(row.line.Content()))
+}
+
+func splitDiffLineItem() fyne.CanvasObject {
+	return container.NewGridWithColumns(2, splitDiffHalfItem(), splitDiffHalfItem())
+}
+
+func splitDiffHalfItem() fyne.CanvasObject {
+	bg := canvas.NewRectangle(nil)
+	lineNo := widget.NewLabelWithStyle("", fyne.TextAlignLeading, monospaceTextStyle)
+	content := widget.NewLabelWithStyle("", fyne.TextAlignLeading, monospaceTextStyle)
+	content.Wrapping = fyne.TextTruncate
+	return container.NewMax(bg, container.NewBorder(nil, nil, lineNo, nil, content))
+}
+
+func updateSplitDiffLineItem(row *splitDiffRow, item fyne.CanvasObject) {
+	halves := item.(*fyne.Container).Objects
+	if row.oldLine == nil && row.newLine == nil {
+		updateSplitDiffHalfItem(halves[0], row.header, 0, diffHunkHeaderColor())
+		updateSplitDiffHalfItem(halves[1], "", 0, diffHunkHeaderColor())
+		return
+	}
+	if row.oldLine == nil {
+		updateSplitDiffHalfItem(halves[0], "", 0, diffEmptyLineColor())
+	} else {
+		updateSplitDiffHalfItem(halves[0], expandTabs(row.oldLine.Content()), row.oldLine.OldLineNo(), diffLineColor(row.oldLine.LineType()))
+	}
+	if row.newLine == nil {
+		updateSplitDiffHalfItem(halves[1], "", 0, diffEmptyLineColor())
+	} else {
+		updateSplitDiffHalfItem(halves[1], expandTabs(row.newLine.Content()), row.newLine.NewLineNo(), diffLineColor(row.newLine.LineType()))
+	}
+}
+
+func updateSplitDiffHalfItem(half fyne.CanvasObject, text string, n int, c color.Color) {
+	objs := half.(*fyne.Container).Objects
+	bg := objs[0].(*canvas.Rectangle)
+	bg.FillColor = c
+	bg.Refresh()
+	border := objs[1].(*fyne.Container).Objects
+	content := border[0].(*widget.Label)
+	lineNo := border[1].(*widget.Label)
+	content.SetText(text)
+	lineNo.SetText(fmt.Sprintf("%5s", lineNoString(n)))
+}
+
+func formatDiffLineNo(oldLineNo, newLineNo int) string {
+	return fmt.Sprintf("%5s %5s", lineNoString(oldLineNo), lineNoString(newLineNo))
+}
+
+func lineNoString(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+func diffLinePrefix(l *repository.DiffLine) string {
+	if markers := l.Markers(); markers != "" {
+		return markers
+	}
+	switch l.LineType() {
+	case repository.DiffAdded:
+		return "+"
+	case repository.DiffDeleted:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", strings.Repeat(" ", diffTabWidth))
+}
+
+func (v *diffView) showFileDiff(rm *repository.RepositoryManager, d *repository.PatchFileDetail) {
+	fd, err := rm.FileDiff(d)
+	if err != nil {
+		v.setRows([]*diffRow{{header: err.Error()}}, []*splitDiffRow{{header: err.Error()}})
+		return
+	}
+	v.setRows(buildDiffRows(fd), buildSplitDiffRows(fd))
+}
+
+func (v *diffView) clear() {
+	v.setRows(make([]*diffRow, 0), make([]*splitDiffRow, 0))
+}
+
+func (v *diffView) setRows(unifiedRows []*diffRow, splitRows []*splitDiffRow) {
+	v.unifiedRows = unifiedRows
+	v.splitRows = splitRows
+	for _, list := range []*widget.List{v.unifiedList, v.splitList} {
+		list.UnselectAll()
+		list.Refresh()
+		list.ScrollToTop()
+	}
+}
+
+func buildDiffRows(fd *repository.FileDiff) []*diffRow {
+	if fd.IsBinary() {
+		return []*diffRow{{header: binaryFileDiffMessage}}
+	}
+	rows := make([]*diffRow, 0)
+	for _, h := range fd.Hunks() {
+		rows = append(rows, &diffRow{header: h.Header()})
+		for _, l := range h.Lines() {
+			rows = append(rows, &diffRow{line: l})
+		}
+	}
+	return rows
+}
+
+// buildSplitDiffRows pairs each run of deleted lines with the following run of
+// added lines so that both sides stay on the same row.
+func buildSplitDiffRows(fd *repository.FileDiff) []*splitDiffRow {
+	if fd.IsBinary() {
+		return []*splitDiffRow{{header: binaryFileDiffMessage}}
+	}
+	rows := make([]*splitDiffRow, 0)
+	for _, h := range fd.Hunks() {
+		rows = append(rows, &splitDiffRow{header: h.Header()})
+		deleted := make([]*repository.DiffLine, 0)
+		added := make([]*repository.DiffLine, 0)
+		flush := func() {
+			for i := 0; i < len(deleted) || i < len(added); i++ {
+				row := &splitDiffRow{}
+				if i < len(deleted) {
+					row.oldLine = deleted[i]
+				}
+				if i < len(added) {
+					row.newLine = added[i]
+				}
+				rows = append(rows, row)
+			}
+			deleted = deleted[:0]
+			added = added[:0]
+		}
+		for _, l := range h.Lines() {
+			switch l.LineType() {
+			case repository.DiffDeleted:
+				if len(added) > 0 {
+					flush()
+				}
+				deleted = append(deleted, l)
+			case repository.DiffAdded:
+				added = append(added, l)
+			default:
+				flush()
+				rows = append(rows, &splitDiffRow{oldLine: l, newLine: l})
+			}
+		}
+		flush()
+	}
+	return rows
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+func (m *manager) buildRefFilterView() fyne.CanvasObject {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("Filter: main feature, main..feature")
+	entry.SetText(m.rm.GraphOption().Revisions)
+	entry.OnSubmitted = m.filterGraph
+	clearButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+		m.filterGraph("")
+	})
+	return container.NewBorder(nil, nil, nil, clearButton, entry)
+}
+
+// filterGraph lays out the graph again with only the commits reachable from the revisions, or all commits if it is empty.
+func (m *manager) filterGraph(revisions string) {
+	revisions = strings.Join(strings.Fields(revisions), " ")
+	m.changeGraphOption(func(o *repository.GraphOption) { o.Revisions = revisions })
+}
+
+func (m *manager) refFilterMenuItems(name string) []*fyne.MenuItem {
+	current := m.rm.GraphOption().Revisions
+	onlyMenuItem := fyne.NewMenuItem("Show only this", func() {
+		m.filterGraph(name)
+	})
+	addMenuItem := fyne.NewMenuItem("Add to filter", func() {
+		m.filterGraph(current + " " + name)
+	})
+	addMenuItem.Disabled = current == ""
+	return []*fyne.MenuItem{onlyMenuItem, addMenuItem}
+}
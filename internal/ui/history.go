@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+var (
+	fileHistoryWindowSize = fyne.NewSize(1200, 800)
+)
+
+// fileContextMenu returns nil for the worktree, whose files are not committed yet.
+func (m *manager) fileContextMenu(n *gogigu.Node, path string) *fyne.Menu {
+	if n == nil || n.IsWorktree() {
+		return nil
+	}
+	historyMenuItem := fyne.NewMenuItem("Show history", func() {
+		m.showFileHistory(n, path)
+	})
+	blameMenuItem := fyne.NewMenuItem("Blame", func() {
+		m.showBlame(n, path)
+	})
+	return fyne.NewMenu("", historyMenuItem, blameMenuItem)
+}
+
+func (m *manager) showFileHistory(n *gogigu.Node, path string) {
+	h, err := m.rm.FileHistory(n, path)
+	if err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	w := fyne.CurrentApp().NewWindow(fmt.Sprintf("History of %s - %s", path, appName))
+	w.SetContent(m.buildFileHistoryContent(h))
+	w.Resize(fileHistoryWindowSize)
+	w.Show()
+}
+
+func (m *manager) buildFileHistoryContent(h *repository.FileHistory) fyne.CanvasObject {
+	dv := newDiffView()
+	list := widget.NewList(
+		func() int {
+			return len(h.Nodes)
+		},
+		func() fyne.CanvasObject {
+			return commitGraphItem(h.Repository)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			updateCommitGraphItem(m.rm, h.Repository, h.Nodes[id], false, item)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		n := h.Nodes[id]
+		d, err := m.fileChangeDetail(n, h.Path(n.Hash()))
+		if err != nil || d == nil {
+			dv.clear()
+			return
+		}
+		dv.showFileDiff(m.rm, d)
+	}
+	vs := container.NewVSplit(list, dv.Container)
+	vs.SetOffset(0.5)
+	return vs
+}
+
+func (m *manager) fileChangeDetail(n *gogigu.Node, path string) (*repository.PatchFileDetail, error) {
+	details, err := m.rm.PatchFileDetails(n, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range details {
+		if d.Name() == path {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+type loadFunc func(context.Context, repository.LoadProgress) (*repository.RepositoryManager, error)
+
+func (m *manager) openRepository(path string) {
+	m.loadRepository(filepath.Base(path), func(ctx context.Context, progress repository.LoadProgress) (*repository.RepositoryManager, error) {
+		return repository.OpenGitRepositoryContext(ctx, path, progress)
+	})
+}
+
+// loadingState is the loading running in the background, if any.
+// mu serializes the start, the cancel and the completion of the loadings, so that only the latest one swaps in its content.
+type loadingState struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	// prev is the content restored when the loading is canceled or fails, and nil if it cannot be restored.
+	prev fyne.CanvasObject
+}
+
+// loadRepository shows the progress while load runs in the background, and swaps in the content when it is done.
+// The previous content is restored if the loading is canceled or fails.
+func (m *manager) loadRepository(name string, load loadFunc) {
+	m.startLoading(name, load, true)
+}
+
+// reloadAfterChange reloads the repository which the app has just changed, like by commit or checkout.
+// It cannot be canceled, since the previous content no longer matches the repository.
+func (m *manager) reloadAfterChange() {
+	if m.rm == nil {
+		return
+	}
+	rm := m.rm
+	m.startLoading(rm.RepositoryName(), func(ctx context.Context, progress repository.LoadProgress) (*repository.RepositoryManager, error) {
+		return rm.Reopen(ctx, progress)
+	}, false)
+}
+
+// startLoading cancels the loading running already, if any, and keeps the content from before it to be restored.
+// The main menu is disabled during the loading.
+func (m *manager) startLoading(name string, load loadFunc, restorable bool) {
+	l := &m.loading
+	l.mu.Lock()
+	if l.cancel != nil {
+		l.cancel()
+	} else {
+		l.prev = m.Content()
+	}
+	if !restorable {
+		l.prev = nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	prev := l.prev
+	l.mu.Unlock()
+
+	phase := widget.NewLabel(loadPhaseText(repository.LoadingWorktreeStatus, 0))
+	objs := []fyne.CanvasObject{
+		widget.NewLabelWithStyle(fmt.Sprintf("Opening %s", name), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		phase,
+		widget.NewProgressBarInfinite(),
+	}
+	if prev != nil {
+		objs = append(objs, widget.NewButton("Cancel", func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if ctx.Err() != nil {
+				return
+			}
+			cancel()
+			l.cancel = nil
+			m.SetMainMenu(m.buildMainMenu())
+			m.SetContent(prev)
+		}))
+	}
+	m.SetMainMenu(m.buildLoadingMenu())
+	m.SetContent(container.NewCenter(container.NewVBox(objs...)))
+
+	go func() {
+		rm, err := load(ctx, func(p repository.LoadPhase, commits int) {
+			phase.SetText(loadPhaseText(p, commits))
+		})
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if ctx.Err() != nil {
+			// canceled, or replaced by another loading
+			return
+		}
+		cancel()
+		l.cancel = nil
+		if err != nil {
+			m.SetMainMenu(m.buildMainMenu())
+			if prev != nil {
+				m.SetContent(prev)
+			} else {
+				m.SetContent(m.buildReloadRequiredView())
+			}
+			dialog.ShowError(err, m.Window)
+			return
+		}
+		m.rm = rm
+		m.draft = &commitDraft{}
+		m.compareBase = ""
+		m.SetMainMenu(m.buildMainMenu())
+		m.SetContent(m.buildContent())
+	}()
+}
+
+// buildLoadingMenu disables all items, so that no other loading starts from the menu by accident.
+func (m *manager) buildLoadingMenu() *fyne.MainMenu {
+	menu := m.buildMainMenu()
+	for _, mm := range menu.Items {
+		for _, item := range mm.Items {
+			item.Disabled = true
+		}
+	}
+	return menu
+}
+
+// buildReloadRequiredView replaces the content which no longer matches the repository changed by the app.
+func (m *manager) buildReloadRequiredView() fyne.CanvasObject {
+	reloadButton := widget.NewButtonWithIcon("Reload", theme.ViewRefreshIcon(), m.reloadAfterChange)
+	return container.NewCenter(container.NewVBox(
+		widget.NewLabel("The repository has been changed and must be reloaded."),
+		reloadButton,
+	))
+}
+
+func loadPhaseText(p repository.LoadPhase, commits int) string {
+	switch p {
+	case repository.LoadingWorktreeStatus:
+		return "Reading worktree status..."
+	case repository.LoadingCommits:
+		return fmt.Sprintf("Reading commits... (%d)", commits)
+	case repository.LoadingLayout:
+		return fmt.Sprintf("Laying out %d commits...", commits)
+	case repository.LoadingRefs:
+		return "Reading refs..."
+	}
+	return ""
+}
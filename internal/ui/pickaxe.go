@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+const (
+	pickaxeOccurrencesOption = "Occurrences of string (-S)"
+	pickaxeLinesOption       = "Changed lines matching regex (-G)"
+)
+
+var (
+	pickaxeWindowSize = fyne.NewSize(1200, 600)
+)
+
+func (m *manager) showPickaxeDialog() {
+	entry := widget.NewEntry()
+	mode := widget.NewRadioGroup([]string{pickaxeOccurrencesOption, pickaxeLinesOption}, nil)
+	mode.SetSelected(pickaxeOccurrencesOption)
+	mode.Required = true
+	items := []*widget.FormItem{
+		widget.NewFormItem("Pattern", entry),
+		widget.NewFormItem("Mode", mode),
+	}
+	callback := func(ok bool) {
+		if !ok {
+			return
+		}
+		q := &repository.PickaxeQuery{Pattern: entry.Text, Mode: repository.PickaxeOccurrences}
+		if mode.Selected == pickaxeLinesOption {
+			q.Mode = repository.PickaxeLines
+		}
+		m.showPickaxe(q)
+	}
+	dialog.ShowForm("Pickaxe search", "Search", "Cancel", items, callback, m.Window)
+}
+
+// showPickaxe runs the search in the background, and it is canceled when the window is closed.
+func (m *manager) showPickaxe(q *repository.PickaxeQuery) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := fyne.CurrentApp().NewWindow(fmt.Sprintf("Pickaxe %q - %s", q.Pattern, appName))
+	w.SetOnClosed(cancel)
+
+	progress := widget.NewProgressBar()
+	cancelButton := widget.NewButton("Cancel", func() {
+		cancel()
+		w.Close()
+	})
+	w.SetContent(container.NewCenter(container.NewVBox(
+		widget.NewLabel("Searching..."),
+		progress,
+		cancelButton,
+	)))
+	w.Resize(pickaxeWindowSize)
+	w.Show()
+
+	rm := m.rm
+	go func() {
+		repo, err := rm.Pickaxe(ctx, q, func(done, total int) {
+			progress.Max = float64(total)
+			progress.SetValue(float64(done))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.SetContent(container.NewCenter(widget.NewLabel(err.Error())))
+			return
+		}
+		w.SetContent(m.buildPickaxeResultContent(rm, repo))
+	}()
+}
+
+func (m *manager) buildPickaxeResultContent(rm *repository.RepositoryManager, repo *gogigu.Repository) fyne.CanvasObject {
+	count := widget.NewLabel(fmt.Sprintf("%d commits found", len(repo.Nodes)))
+	list := widget.NewList(
+		func() int {
+			return len(repo.Nodes)
+		},
+		func() fyne.CanvasObject {
+			return commitGraphItem(repo)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			updateCommitGraphItem(rm, repo, repo.Nodes[id], false, item)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		if m.rm != rm {
+			// the repository has been reopened
+			return
+		}
+		m.selectCommit(repo.Nodes[id].Hash())
+	}
+	return container.NewBorder(count, nil, nil, nil, list)
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+// refTreeNode is a folder or a ref in the side menu tree.
+// The ids of the nodes are the section names followed by the paths split on `/`, like `Remote Branches/origin/feature`.
+type refTreeNode struct {
+	label string
+	// ref is the full name of the ref, like `refs/heads/main`, and empty for folders.
+	ref      string
+	children []widget.TreeNodeID
+	// count is the number of the refs under the folder.
+	count int
+}
+
+func (n *refTreeNode) isFolder() bool {
+	return n.ref == ""
+}
+
+func (n *refTreeNode) folderText() string {
+	return fmt.Sprintf("%s (%d)", n.label, n.count)
+}
+
+// buildRefTree nests the refs whose names contain filter, ignoring case, on `/` under the sections.
+func (m *manager) buildRefTree(filter string) map[widget.TreeNodeID]*refTreeNode {
+	filter = strings.ToLower(filter)
+	nodes := map[widget.TreeNodeID]*refTreeNode{
+		"": {children: []widget.TreeNodeID{sideMenuLocalBranches, sideMenuRemoteBranches, sideMenuTags}},
+	}
+	add := func(section string, refs []*repository.Ref) {
+		root := &refTreeNode{label: section}
+		nodes[section] = root
+		for _, ref := range refs {
+			name := ref.Name()
+			if !strings.Contains(strings.ToLower(name), filter) {
+				continue
+			}
+			root.count++
+			parent, path := root, section
+			parts := strings.Split(name, "/")
+			for _, p := range parts[:len(parts)-1] {
+				path += "/" + p
+				folder, ok := nodes[path]
+				if !ok {
+					folder = &refTreeNode{label: p}
+					nodes[path] = folder
+					parent.children = append(parent.children, path)
+				}
+				folder.count++
+				parent = folder
+			}
+			id := section + "/" + name
+			nodes[id] = &refTreeNode{label: parts[len(parts)-1], ref: ref.FullName()}
+			parent.children = append(parent.children, id)
+		}
+	}
+	add(sideMenuLocalBranches, m.rm.BranchRefs())
+	add(sideMenuRemoteBranches, m.rm.RemoteBranchRefs())
+	add(sideMenuTags, m.rm.SortedTagRefs())
+	return nodes
+}
+
+// refreshSideMenu reads the refs again, so that the tree and the HEAD status show the latest refs.
+func (m *manager) refreshSideMenu() {
+	v := m.sideMenuView
+	v.head.SetText(headStatusText(m.rm))
+	v.nodes = m.buildRefTree(v.filter)
+	v.tree.Refresh()
+	if v.filter != "" {
+		v.tree.OpenAllBranches()
+	}
+}
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+const (
+	searchDateEntryWidth = 180.
+)
+
+type searchBarView struct {
+	*fyne.Container
+	textEntry   *widget.Entry
+	regexpCheck *widget.Check
+	sinceEntry  *widget.Entry
+	untilEntry  *widget.Entry
+	status      *widget.Label
+
+	matches gogigu.Nodes
+	matched map[string]struct{}
+	current int
+}
+
+func (m *manager) buildSearchBarView() fyne.CanvasObject {
+	v := &searchBarView{
+		matched: make(map[string]struct{}),
+	}
+	search := func(string) {
+		m.search()
+	}
+	v.textEntry = widget.NewEntry()
+	v.textEntry.SetPlaceHolder("Search message, author, email or hash")
+	v.textEntry.OnSubmitted = search
+	v.regexpCheck = widget.NewCheck("Regex", func(bool) {
+		m.search()
+	})
+	v.sinceEntry = newDateEntry("Since", search)
+	v.untilEntry = newDateEntry("Until", search)
+	v.status = widget.NewLabel("")
+	prevButton := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		m.moveSearchMatch(-1)
+	})
+	nextButton := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+		m.moveSearchMatch(1)
+	})
+	clearButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), m.clearSearch)
+	pickaxeButton := widget.NewButton("Pickaxe...", m.showPickaxeDialog)
+	dateEntrySize := fyne.NewSize(searchDateEntryWidth, v.sinceEntry.MinSize().Height)
+	options := container.NewHBox(
+		v.regexpCheck,
+		container.NewGridWrap(dateEntrySize, v.sinceEntry),
+		container.NewGridWrap(dateEntrySize, v.untilEntry),
+		v.status,
+		prevButton,
+		nextButton,
+		clearButton,
+		pickaxeButton,
+	)
+	v.Container = container.NewBorder(nil, nil, widget.NewIcon(theme.SearchIcon()), options, v.textEntry)
+	m.searchBarView = v
+	return v.Container
+}
+
+func newDateEntry(placeHolder string, onSubmitted func(string)) *widget.Entry {
+	e := widget.NewEntry()
+	e.SetPlaceHolder(fmt.Sprintf("%s (%s)", placeHolder, strings.ToUpper(dateFormat)))
+	e.Validator = func(s string) error {
+		_, err := parseDate(s)
+		return err
+	}
+	e.OnSubmitted = onSubmitted
+	return e
+}
+
+// parseDate returns the zero time for an empty string.
+func parseDate(s string) (time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(dateFormat, strings.TrimSpace(s), time.Local)
+}
+
+func (v *searchBarView) query() (*repository.SearchQuery, error) {
+	since, err := parseDate(v.sinceEntry.Text)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseDate(v.untilEntry.Text)
+	if err != nil {
+		return nil, err
+	}
+	if !until.IsZero() {
+		// include the whole day
+		until = until.AddDate(0, 0, 1)
+	}
+	return &repository.SearchQuery{
+		Text:   v.textEntry.Text,
+		Regexp: v.regexpCheck.Checked,
+		Since:  since,
+		Until:  until,
+	}, nil
+}
+
+func (v *searchBarView) isEmpty() bool {
+	return v.textEntry.Text == "" && v.sinceEntry.Text == "" && v.untilEntry.Text == ""
+}
+
+func (v *searchBarView) isMatched(n *gogigu.Node) bool {
+	if v == nil {
+		return false
+	}
+	_, ok := v.matched[n.Hash()]
+	return ok
+}
+
+func (m *manager) search() {
+	v := m.searchBarView
+	if v.isEmpty() {
+		m.clearSearch()
+		return
+	}
+	q, err := v.query()
+	if err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	matches, err := m.rm.Search(q)
+	if err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	v.matches = matches
+	v.matched = make(map[string]struct{})
+	for _, n := range matches {
+		v.matched[n.Hash()] = struct{}{}
+	}
+	v.current = -1
+	m.commitGraphView.List.Refresh()
+	m.moveSearchMatch(1)
+}
+
+// moveSearchMatch selects the next match if d is positive, or the previous one if negative, wrapping around.
+func (m *manager) moveSearchMatch(d int) {
+	v := m.searchBarView
+	n := len(v.matches)
+	if n == 0 {
+		v.status.SetText(searchStatusText(v))
+		return
+	}
+	v.current = ((v.current+d)%n + n) % n
+	v.status.SetText(searchStatusText(v))
+	m.commitGraphView.List.Select(v.matches[v.current].PosY())
+}
+
+func (m *manager) clearSearch() {
+	v := m.searchBarView
+	v.textEntry.SetText("")
+	v.sinceEntry.SetText("")
+	v.untilEntry.SetText("")
+	v.matches = nil
+	v.matched = make(map[string]struct{})
+	v.current = -1
+	v.status.SetText("")
+	m.commitGraphView.List.Refresh()
+}
+
+func searchStatusText(v *searchBarView) string {
+	if len(v.matches) == 0 {
+		return "No matches"
+	}
+	return fmt.Sprintf("%d / %d", v.current+1, len(v.matches))
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+func (m *manager) tagContextMenu(name string) *fyne.Menu {
+	deleteMenuItem := fyne.NewMenuItem("Delete...", func() {
+		m.confirmDeleteTag(name)
+	})
+	return fyne.NewMenu("", deleteMenuItem)
+}
+
+func (m *manager) showCreateTagDialog(n *gogigu.Node) {
+	nameEntry := widget.NewEntry()
+	nameEntry.Validator = repository.ValidateRefName
+	messageEntry := widget.NewMultiLineEntry()
+	messageEntry.SetPlaceHolder("Leave empty for a lightweight tag")
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Message", messageEntry),
+	}
+	callback := func(ok bool) {
+		if !ok {
+			return
+		}
+		m.updateRefs(m.rm.CreateTag(nameEntry.Text, n.Hash(), messageEntry.Text))
+	}
+	title := fmt.Sprintf("Create tag at %s", n.ShortHash())
+	dialog.ShowForm(title, "Create", "Cancel", items, callback, m.Window)
+}
+
+func (m *manager) confirmDeleteTag(name string) {
+	callback := func(ok bool) {
+		if !ok {
+			return
+		}
+		m.updateRefs(m.rm.DeleteTag(name))
+	}
+	dialog.ShowConfirm("Delete tag", fmt.Sprintf("Delete tag %s?", name), callback, m.Window)
+}
+
+func annotatedTagDetail(t *repository.Ref) fyne.CanvasObject {
+	tagger := t.Tagger()
+	taggerDetail := container.NewHBox(
+		widget.NewLabel(tagger.Name),
+		widget.NewLabel(formatEmail(tagger.Email)),
+		widget.NewLabel(tagger.When.Format(dateTimeFormat)),
+	)
+	message := widget.NewLabel(strings.TrimRight(t.TagMessage(), "\n"))
+	message.Wrapping = fyne.TextWrapWord
+	return container.NewVBox(taggerDetail, message)
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"fmt"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -48,3 +49,17 @@ func dummyPaddingSpaces(w float32) string {
 	pad := strings.Repeat(" ", 120)
 	return cutText(pad, w, buf, buf, 8)
 }
+
+func formatFileSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	f := float64(size)
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
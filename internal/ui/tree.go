@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+var (
+	blobViewerWindowSize = fyne.NewSize(800, 600)
+)
+
+type treeBrowserView struct {
+	*widget.Tree
+
+	node    *gogigu.Node
+	entries map[string]*repository.TreeEntry
+	dirs    map[string][]widget.TreeNodeID
+}
+
+func (m *manager) buildTreeBrowserView() fyne.CanvasObject {
+	v := &treeBrowserView{}
+	v.reset(nil)
+	tree := widget.NewTree(
+		func(uid widget.TreeNodeID) []widget.TreeNodeID {
+			return v.children(m.rm, uid)
+		},
+		func(uid widget.TreeNodeID) bool {
+			if uid == "" {
+				return true
+			}
+			e, ok := v.entries[uid]
+			return ok && e.IsDir()
+		},
+		func(branch bool) fyne.CanvasObject {
+			return newContextMenuTarget(treeEntryItem())
+		},
+		func(uid widget.TreeNodeID, branch bool, item fyne.CanvasObject) {
+			e, ok := v.entries[uid]
+			if !ok {
+				return
+			}
+			t := item.(*contextMenuTarget)
+			updateTreeEntryItem(e, t.content)
+			t.menu = func() *fyne.Menu {
+				if e.IsDir() || v.node == nil {
+					return nil
+				}
+				return m.fileContextMenu(v.node, e.Path())
+			}
+		},
+	)
+	tree.OnSelected = func(uid widget.TreeNodeID) {
+		e, ok := v.entries[uid]
+		if !ok || v.node == nil {
+			return
+		}
+		if e.IsDir() {
+			tree.ToggleBranch(uid)
+		} else if e.Mode().IsFile() {
+			m.showBlobViewer(v.node, e.Path())
+		}
+		tree.Unselect(uid)
+	}
+	v.Tree = tree
+	m.treeBrowserView = v
+	return v.Tree
+}
+
+func (v *treeBrowserView) reset(n *gogigu.Node) {
+	v.node = n
+	v.entries = make(map[string]*repository.TreeEntry)
+	v.dirs = make(map[string][]widget.TreeNodeID)
+}
+
+// children loads the entries of a directory on demand, the first time the directory is opened.
+func (v *treeBrowserView) children(rm *repository.RepositoryManager, uid widget.TreeNodeID) []widget.TreeNodeID {
+	if v.node == nil {
+		return []widget.TreeNodeID{}
+	}
+	if ids, ok := v.dirs[uid]; ok {
+		return ids
+	}
+	entries, err := rm.TreeEntries(v.node, uid)
+	if err != nil {
+		entries = make([]*repository.TreeEntry, 0)
+	}
+	ids := make([]widget.TreeNodeID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.Path()
+		v.entries[e.Path()] = e
+	}
+	v.dirs[uid] = ids
+	return ids
+}
+
+func (m *manager) updateTreeBrowserView(n *gogigu.Node) {
+	if n != nil && n.IsWorktree() {
+		// the worktree has no tree object to browse
+		n = nil
+	}
+	v := m.treeBrowserView
+	v.reset(n)
+	v.Tree.Refresh()
+}
+
+func treeEntryItem() fyne.CanvasObject {
+	icon := widget.NewIcon(nil)
+	name := widget.NewLabel("")
+	mode := widget.NewLabelWithStyle("", fyne.TextAlignTrailing, monospaceTextStyle)
+	size := widget.NewLabelWithStyle("", fyne.TextAlignTrailing, monospaceTextStyle)
+	return container.NewBorder(
+		nil, nil,
+		container.NewHBox(icon, name), container.NewHBox(mode, size),
+	)
+}
+
+func updateTreeEntryItem(e *repository.TreeEntry, item fyne.CanvasObject) {
+	objs := item.(*fyne.Container).Objects
+	left := objs[0].(*fyne.Container).Objects
+	right := objs[1].(*fyne.Container).Objects
+	icon := theme.FileIcon()
+	if e.IsDir() {
+		icon = theme.FolderIcon()
+	}
+	left[0].(*widget.Icon).SetResource(icon)
+	left[1].(*widget.Label).SetText(e.Name())
+	right[0].(*widget.Label).SetText(fmt.Sprintf("%06o", uint32(e.Mode())))
+	size := ""
+	if e.Mode().IsFile() {
+		size = formatFileSize(e.Size())
+	}
+	right[1].(*widget.Label).SetText(size)
+}
+
+func (m *manager) showBlobViewer(n *gogigu.Node, path string) {
+	w := fyne.CurrentApp().NewWindow(fmt.Sprintf("%s @ %s - %s", path, n.ShortHash(), appName))
+	w.SetContent(m.buildBlobViewerContent(n, path))
+	w.Resize(blobViewerWindowSize)
+	w.Show()
+}
+
+func (m *manager) buildBlobViewerContent(n *gogigu.Node, path string) fyne.CanvasObject {
+	content, binary, err := m.rm.FileContent(n, path)
+	if err != nil {
+		return widget.NewLabel(err.Error())
+	}
+	if binary {
+		return container.NewCenter(widget.NewLabel("Binary file"))
+	}
+	lines := repository.FileLines(content)
+	return widget.NewList(
+		func() int {
+			return len(lines)
+		},
+		func() fyne.CanvasObject {
+			lineNo := widget.NewLabelWithStyle("", fyne.TextAlignTrailing, monospaceTextStyle)
+			text := widget.NewLabelWithStyle("", fyne.TextAlignLeading, monospaceTextStyle)
+			return container.NewHBox(lineNo, text)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			objs := item.(*fyne.Container).Objects
+			objs[0].(*widget.Label).SetText(fmt.Sprintf("%5d", id+1))
+			objs[1].(*widget.Label).SetText(expandTabs(lines[id]))
+		},
+	)
+}
@@ -1,9 +1,14 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"image/color"
 	"log"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -22,10 +27,14 @@ const (
 
 const (
 	dateTimeFormat = "2006/01/02 15:04:05"
+	dateFormat     = "2006/01/02"
 
 	graphMessageColumnWidth = 500.
 	graphHashColumnWidth    = 80.
 	graphAuthorColumnWidth  = 160.
+
+	lineStatBarBlocks    = 5
+	lineStatBarBlockSize = 8
 )
 
 var (
@@ -40,18 +49,32 @@ type manager struct {
 	*commitDetailView
 	*sideMenuView
 	*patchSummaryView
+	*treeBrowserView
+	*diffView
+	*searchBarView
+
+	draft *commitDraft
+	// compareBase is the hash or the ref name selected to be compared with another one.
+	compareBase string
+
+	loading loadingState
 }
 
-func Start(w fyne.Window, rm *repository.RepositoryManager) {
+// Start shows the window, and opens the repository in the background if path is not empty.
+func Start(w fyne.Window, path string) {
 	m := &manager{
 		Window:           w,
-		rm:               rm,
+		rm:               nil,
 		commitGraphView:  nil,
 		commitDetailView: nil,
 		sideMenuView:     nil,
+		draft:            &commitDraft{},
 	}
 	m.SetMainMenu(m.buildMainMenu())
 	m.SetContent(m.buildContent())
+	if path != "" {
+		m.openRepository(path)
+	}
 	m.Resize(defaultWindowSize)
 	m.ShowAndRun()
 }
@@ -73,11 +96,20 @@ func (m *manager) buildContent() fyne.CanvasObject {
 		return m.buildEmptyView()
 	}
 
+	commitTabs := container.NewAppTabs(
+		container.NewTabItem("Changes", m.buildPatchSummaryView()),
+		container.NewTabItem("Tree", m.buildTreeBrowserView()),
+	)
+	patchHs := container.NewHSplit(
+		commitTabs,
+		m.buildDiffView(),
+	)
+	patchHs.SetOffset(0.3)
 	commitInfoHs := container.NewHSplit(
 		m.buildCommitDetailView(),
-		m.buildPatchSummaryView(),
+		patchHs,
 	)
-	commitInfoHs.SetOffset(0.7)
+	commitInfoHs.SetOffset(0.4)
 	logVs := container.NewVSplit(
 		m.buildCommitGraphView(),
 		commitInfoHs,
@@ -93,9 +125,55 @@ func (m *manager) buildContent() fyne.CanvasObject {
 
 func (m *manager) buildMainMenu() *fyne.MainMenu {
 	openMenuItem := fyne.NewMenuItem("Open...", m.showRepositoryOpenDialog)
+	reloadMenuItem := fyne.NewMenuItem("Reload", m.reloadRepository)
 	closeMenuItem := fyne.NewMenuItem("Close repository", m.closeRepository)
-	fileMenu := fyne.NewMenu("File", openMenuItem, fyne.NewMenuItemSeparator(), closeMenuItem)
-	return fyne.NewMainMenu(fileMenu)
+	fileMenu := fyne.NewMenu("File", openMenuItem, reloadMenuItem, fyne.NewMenuItemSeparator(), closeMenuItem)
+	viewMenu := fyne.NewMenu("View", append(m.graphOptionMenuItems(),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Rename detection...", m.showRenameThresholdDialog),
+	)...)
+	return fyne.NewMainMenu(fileMenu, viewMenu)
+}
+
+// graphOptionMenuItems are checked by the option of the repository opened, so the menu must be rebuilt when it changes.
+func (m *manager) graphOptionMenuItems() []*fyne.MenuItem {
+	var opt repository.GraphOption
+	if m.rm != nil {
+		opt = m.rm.GraphOption()
+	}
+	dateOrderMenuItem := fyne.NewMenuItem("Commit date order", func() {
+		m.changeGraphOption(func(o *repository.GraphOption) { o.Sort = gogigu.CommitDate })
+	})
+	dateOrderMenuItem.Checked = m.rm != nil && opt.Sort == gogigu.CommitDate
+	topoOrderMenuItem := fyne.NewMenuItem("Topological order", func() {
+		m.changeGraphOption(func(o *repository.GraphOption) { o.Sort = gogigu.Topological })
+	})
+	topoOrderMenuItem.Checked = m.rm != nil && opt.Sort == gogigu.Topological
+	// the history is streamed in this order, so large repositories are shown without reading all commits first
+	chronologicalOrderMenuItem := fyne.NewMenuItem("Chronological order", func() {
+		m.changeGraphOption(func(o *repository.GraphOption) { o.Sort = gogigu.Chronological })
+	})
+	chronologicalOrderMenuItem.Checked = m.rm != nil && opt.Sort == gogigu.Chronological
+	firstParentMenuItem := fyne.NewMenuItem("First-parent only", func() {
+		m.changeGraphOption(func(o *repository.GraphOption) { o.FirstParent = !o.FirstParent })
+	})
+	firstParentMenuItem.Checked = opt.FirstParent
+	return []*fyne.MenuItem{dateOrderMenuItem, topoOrderMenuItem, chronologicalOrderMenuItem, firstParentMenuItem}
+}
+
+func (m *manager) changeGraphOption(update func(*repository.GraphOption)) {
+	if m.rm == nil {
+		return
+	}
+	rm := m.rm
+	opt := rm.GraphOption()
+	update(&opt)
+	if opt == rm.GraphOption() {
+		return
+	}
+	m.loadRepository(rm.RepositoryName(), func(ctx context.Context, progress repository.LoadProgress) (*repository.RepositoryManager, error) {
+		return rm.ReopenWithGraphOption(ctx, opt, progress)
+	})
 }
 
 func (m *manager) buildEmptyView() fyne.CanvasObject {
@@ -116,15 +194,52 @@ func (m *manager) showRepositoryOpenDialog() {
 		if lu == nil {
 			return // canceled
 		}
-		rm, err := repository.OpenGitRepository(lu.String()[7:]) // `file://`
+		m.openRepository(lu.String()[7:]) // `file://`
+	}
+	dialog.ShowFolderOpen(callback, m.Window)
+}
+
+func (m *manager) showRenameThresholdDialog() {
+	if m.rm == nil {
+		return
+	}
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(m.rm.RenameThreshold()))
+	entry.Validator = func(s string) error {
+		n, err := strconv.Atoi(s)
 		if err != nil {
+			return err
+		}
+		if n < 1 || 100 < n {
+			return fmt.Errorf("must be between 1 and 100")
+		}
+		return nil
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("Similarity threshold (%)", entry),
+	}
+	callback := func(ok bool) {
+		if !ok {
+			return
+		}
+		n, _ := strconv.Atoi(entry.Text)
+		if err := m.rm.SetRenameThreshold(n); err != nil {
 			dialog.ShowError(err, m.Window)
 			return
 		}
-		m.rm = rm
-		m.SetContent(m.buildContent())
+		m.refreshCommitViews()
 	}
-	dialog.ShowFolderOpen(callback, m.Window)
+	dialog.ShowForm("Rename detection", "OK", "Cancel", items, callback, m.Window)
+}
+
+func (m *manager) reloadRepository() {
+	if m.rm == nil {
+		return
+	}
+	rm := m.rm
+	m.loadRepository(rm.RepositoryName(), func(ctx context.Context, progress repository.LoadProgress) (*repository.RepositoryManager, error) {
+		return rm.Reopen(ctx, progress)
+	})
 }
 
 func (m *manager) closeRepository() {
@@ -132,6 +247,7 @@ func (m *manager) closeRepository() {
 		return
 	}
 	m.rm = nil
+	m.SetMainMenu(m.buildMainMenu())
 	m.SetContent(m.buildContent())
 }
 
@@ -146,40 +262,87 @@ func (m *manager) buildCommitGraphView() fyne.CanvasObject {
 	}
 	list := widget.NewList(
 		func() int {
-			return len(m.rm.Nodes)
+			return m.rm.Len()
 		},
 		func() fyne.CanvasObject {
-			return commitGraphItem(m.rm)
+			return newContextMenuTarget(commitGraphItem(m.rm.Repository))
 		},
 		func(id widget.ListItemID, item fyne.CanvasObject) {
-			updateCommitGraphItem(m.rm, m.rm.Nodes[id], item)
+			n := m.rm.NodeAt(id)
+			t := item.(*contextMenuTarget)
+			updateCommitGraphItem(m.rm, m.rm.Repository, n, m.searchBarView.isMatched(n), t.content)
+			t.menu = func() *fyne.Menu {
+				return m.commitContextMenu(n)
+			}
 		},
 	)
+	// the rows already shown must follow the wider graph area, and the list gets longer as the history is streamed
+	m.rm.OnLayoutChanged(list.Refresh)
 	list.OnSelected = func(id widget.ListItemID) {
-		m.updateCommitDetailView(id)
-		m.updatePatchSummaryView(id)
+		n := m.rm.NodeAt(id)
+		m.patchSummaryView.resetParentSelect(n)
+		m.updateCommitViews(n, 0)
+		m.updateTreeBrowserView(n)
 	}
 	v.List = list
 	m.commitGraphView = v
-	return list
+	return container.NewBorder(m.buildSearchBarView(), nil, nil, nil, list)
+}
+
+// updateCommitViews lists the changed files in the background, and then fills in their line stats one by one.
+// The listing is canceled when another commit is selected.
+func (m *manager) updateCommitViews(n *gogigu.Node, parent int) {
+	v := m.patchSummaryView
+	v.mu.Lock()
+	if v.cancel != nil {
+		v.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	v.cancel = cancel
+	v.mu.Unlock()
+
+	rm := m.rm
+	go func() {
+		details, err := rm.PatchFileDetails(n, parent)
+		if err != nil {
+			details = make([]*repository.PatchFileDetail, 0)
+		}
+		if !v.apply(ctx, func() {
+			m.updateCommitDetailView(n, details)
+			m.updatePatchSummaryView(details)
+		}) {
+			return
+		}
+		repository.CalculateLineStats(ctx, details, func(int) {
+			v.apply(ctx, func() {
+				m.commitDetailView.changes.SetText(changesSummary(details))
+				v.list.Refresh()
+			})
+		})
+	}()
+}
+
+func (m *manager) refreshCommitViews() {
+	v := m.patchSummaryView
+	if v == nil || v.node == nil {
+		return
+	}
+	m.updateCommitViews(v.node, v.parent)
 }
 
-func commitGraphItem(rm *repository.RepositoryManager) fyne.CanvasObject {
-	graphAreaWidth := graph.CalcCommitGraphAreaWidth(rm)
+func commitGraphItem(repo *gogigu.Repository) fyne.CanvasObject {
+	graphAreaWidth := graph.CalcCommitGraphAreaWidth(repo)
+	highlight := canvas.NewRectangle(color.Transparent)
 	graphArea := widget.NewLabel("")
 	refs := widget.NewLabel("")
 	msg := widget.NewLabel("commit message")
 	hash := widget.NewLabel("hash")
 	author := widget.NewLabel("author")
 	committedAt := widget.NewLabel("2006/01/02 15:04:05")
-	var msgW, hashW, authorW float32 = graphMessageColumnWidth, graphHashColumnWidth, graphAuthorColumnWidth
 	graphArea.Move(fyne.NewPos(0, 0))
 	refs.Move(fyne.NewPos(graphArea.Position().X+graphAreaWidth, 0))
-	msg.Move(fyne.NewPos(graphArea.Position().X+graphAreaWidth, 0))
-	hash.Move(fyne.NewPos(msg.Position().X+msgW, 0))
-	author.Move(fyne.NewPos(hash.Position().X+hashW, 0))
-	committedAt.Move(fyne.NewPos(author.Position().X+authorW, 0))
-	return container.NewWithoutLayout(
+	item := container.NewWithoutLayout(
+		highlight,
 		graphArea,
 		refs,
 		msg,
@@ -187,20 +350,38 @@ func commitGraphItem(rm *repository.RepositoryManager) fyne.CanvasObject {
 		author,
 		committedAt,
 	)
+	moveCommitGraphColumns(item.Objects, graphAreaWidth)
+	return item
 }
 
-func updateCommitGraphItem(rm *repository.RepositoryManager, node *gogigu.Node, item fyne.CanvasObject) {
-	objs := item.(*fyne.Container).Objects
-	objs[0] = graph.CalcCommitGraphTreeRow(rm, node, item.Size().Height)
-	refs, rw := calcCommitRefMarkers(rm, node, item.Size().Height)
-	objs[1] = refs
-	objs[2].(*widget.Label).SetText(summaryMessage(node, rw))
-	objs[3].(*widget.Label).SetText(shortHash(node))
-	objs[4].(*widget.Label).SetText(authorName(node))
-	objs[5].(*widget.Label).SetText(commitedAt(node))
+// moveCommitGraphColumns places the columns after the graph area, which gets wider as more rows are laid out.
+func moveCommitGraphColumns(objs []fyne.CanvasObject, graphAreaWidth float32) {
+	var msgW, hashW, authorW float32 = graphMessageColumnWidth, graphHashColumnWidth, graphAuthorColumnWidth
+	msg, hash, author, committedAt := objs[3], objs[4], objs[5], objs[6]
+	msg.Move(fyne.NewPos(graphAreaWidth, 0))
+	hash.Move(fyne.NewPos(msg.Position().X+msgW, 0))
+	author.Move(fyne.NewPos(hash.Position().X+hashW, 0))
+	committedAt.Move(fyne.NewPos(author.Position().X+authorW, 0))
 }
 
-func calcCommitRefMarkers(rm *repository.RepositoryManager, node *gogigu.Node, h float32) (fyne.CanvasObject, float32) {
+// updateCommitGraphItem draws the graph of repo, which may be a subset of the repository of rm.
+func updateCommitGraphItem(rm *repository.RepositoryManager, repo *gogigu.Repository, node *gogigu.Node, highlighted bool, item fyne.CanvasObject) {
+	objs := item.(*fyne.Container).Objects
+	highlight := objs[0].(*canvas.Rectangle)
+	highlight.FillColor = searchHighlightColor(highlighted)
+	highlight.Resize(item.Size())
+	highlight.Refresh()
+	objs[1] = graph.CalcCommitGraphTreeRow(repo, node, item.Size().Height)
+	moveCommitGraphColumns(objs, graph.CalcCommitGraphAreaWidth(repo))
+	refs, rw := calcCommitRefMarkers(rm, repo, node, item.Size().Height)
+	objs[2] = refs
+	objs[3].(*widget.Label).SetText(summaryMessage(node, rw))
+	objs[4].(*widget.Label).SetText(shortHash(node))
+	objs[5].(*widget.Label).SetText(authorName(node))
+	objs[6].(*widget.Label).SetText(commitedAt(node))
+}
+
+func calcCommitRefMarkers(rm *repository.RepositoryManager, repo *gogigu.Repository, node *gogigu.Node, h float32) (fyne.CanvasObject, float32) {
 	refs := rm.AllRefs(node.Hash())
 	if len(refs) == 0 {
 		return container.NewWithoutLayout(), 0
@@ -233,7 +414,7 @@ func calcCommitRefMarkers(rm *repository.RepositoryManager, node *gogigu.Node, h
 			n += 1
 		}
 	}
-	left := graph.CalcCommitGraphAreaWidth(rm)
+	left := graph.CalcCommitGraphAreaWidth(repo)
 	markers.Move(fyne.NewPos(left, 0))
 	return markers, totalWidth
 }
@@ -249,7 +430,10 @@ func buildCommitRefMarkers(refs []*repository.Ref, h float32) (fyne.CanvasObject
 			StrokeColor: fg,
 			StrokeWidth: 1,
 		}
-		name := ref.Name()
+		if ref.RefType() == repository.Head {
+			rect.StrokeWidth = 2
+		}
+		name := refLabelText(ref)
 		textSize := textSize(name)
 		rectWidth := textSize.Width + wBuf*2
 		rectHeight := textSize.Height + hBuf*2
@@ -269,6 +453,9 @@ func summaryMessage(node *gogigu.Node, refsWidth float32) string {
 }
 
 func shortHash(node *gogigu.Node) string {
+	if node.IsWorktree() {
+		return ""
+	}
 	return node.ShortHash()
 }
 
@@ -277,11 +464,16 @@ func authorName(node *gogigu.Node) string {
 }
 
 func commitedAt(node *gogigu.Node) string {
+	if node.IsWorktree() {
+		return ""
+	}
 	return node.Commit.Author.When.Format(dateTimeFormat)
 }
 
 type commitDetailView struct {
 	*container.Scroll
+	// changes shows the summary of the line stats, which are calculated after the view is shown.
+	changes *widget.Label
 }
 
 func (m *manager) buildCommitDetailView() fyne.CanvasObject {
@@ -293,8 +485,12 @@ func (m *manager) buildCommitDetailView() fyne.CanvasObject {
 	return v.Scroll
 }
 
-func (m *manager) updateCommitDetailView(id widget.ListItemID) {
-	n := m.rm.Nodes[id]
+func (m *manager) updateCommitDetailView(n *gogigu.Node, details []*repository.PatchFileDetail) {
+	if n.IsWorktree() {
+		m.updateWorktreeDetailView(n, details)
+		return
+	}
+
 	form := widget.NewForm()
 
 	authorItemNameLabel := widget.NewLabel(n.Commit.Author.Name)
@@ -334,6 +530,13 @@ func (m *manager) updateCommitDetailView(id widget.ListItemID) {
 		form.AppendItem(refsItem)
 	}
 
+	for _, t := range m.rm.AnnotatedTagsAt(n.Hash()) {
+		form.Append(fmt.Sprintf("Tag %s", t.Name()), annotatedTagDetail(t))
+	}
+
+	changes := widget.NewLabel(changesSummary(details))
+	form.Append("Changes", changes)
+
 	messageItemRichText := widget.NewRichText()
 	messageItemRichText.Wrapping = fyne.TextWrapWord
 	msgHead, msgTail := parseCommitMessage(n)
@@ -353,15 +556,16 @@ func (m *manager) updateCommitDetailView(id widget.ListItemID) {
 	form.Append("", messageItemRichText)
 
 	v := m.commitDetailView
+	v.changes = changes
 	v.Scroll.Content = form
 	v.Scroll.Refresh()
 }
 
 func (m *manager) parentsShortHashes(n *gogigu.Node) string {
-	ps := m.rm.Parents(n.Hash())
+	ps := n.Commit.ParentHashes
 	hs := make([]string, len(ps))
 	for i, p := range ps {
-		hs[i] = p.ShortHash()
+		hs[i] = p.String()[:7]
 	}
 	return strings.Join(hs, " ")
 }
@@ -374,6 +578,21 @@ func parseCommitMessage(n *gogigu.Node) (string, string) {
 	return msgs[0], ""
 }
 
+func changesSummary(details []*repository.PatchFileDetail) string {
+	added, deleted := repository.TotalLineStats(details)
+	files := "files"
+	if len(details) == 1 {
+		files = "file"
+	}
+	summary := fmt.Sprintf("%d %s changed, +%d -%d", len(details), files, added, deleted)
+	for _, d := range details {
+		if !d.LineStatsCalculated() {
+			return summary + " (calculating...)"
+		}
+	}
+	return summary
+}
+
 func formatEmail(email string) string {
 	return fmt.Sprintf("<%s>", email)
 }
@@ -385,29 +604,141 @@ func showCommiter(n *gogigu.Node) bool {
 }
 
 type sideMenuView struct {
-	*widget.Tree
+	*fyne.Container
+	tree *widget.Tree
+	head *widget.Button
+
+	nodes  map[widget.TreeNodeID]*refTreeNode
+	filter string
 }
 
 func (m *manager) buildSideMenuView() fyne.CanvasObject {
-	v := &sideMenuView{}
-	tree := widget.NewTreeWithStrings(map[string][]string{
-		"":                {"Local Branches", "Remote Branches", "Tags"},
-		"Local Branches":  m.rm.BranchNames(),
-		"Remote Branches": m.rm.RemoteBranchNames(),
-		"Tags":            m.rm.SortedTagNames(),
-	})
-	tree.OnSelected = m.selectRefRow
-	v.Tree = tree
+	v := &sideMenuView{
+		nodes: m.buildRefTree(""),
+	}
+	tree := widget.NewTree(
+		func(uid widget.TreeNodeID) []widget.TreeNodeID {
+			if n, ok := v.nodes[uid]; ok {
+				return n.children
+			}
+			return []widget.TreeNodeID{}
+		},
+		func(uid widget.TreeNodeID) bool {
+			n, ok := v.nodes[uid]
+			return ok && n.isFolder()
+		},
+		func(branch bool) fyne.CanvasObject {
+			return newContextMenuTarget(widget.NewLabel(""))
+		},
+		func(uid widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
+			t := obj.(*contextMenuTarget)
+			l := t.content.(*widget.Label)
+			n, ok := v.nodes[uid]
+			if !ok || n.isFolder() {
+				t.menu = nil
+				l.TextStyle.Bold = false
+				if ok {
+					l.SetText(n.folderText())
+				}
+				return
+			}
+			ref := m.rm.FromFullRefName(n.ref)
+			l.TextStyle.Bold = ref != nil && ref.RefType() == repository.Branch && ref.Name() == m.rm.CurrentBranch()
+			text := n.label
+			if ref != nil {
+				if tracking := trackingText(ref); tracking != "" {
+					text = fmt.Sprintf("%s %s", text, tracking)
+				}
+			}
+			l.SetText(text)
+			t.menu = func() *fyne.Menu {
+				return m.refContextMenu(n.ref)
+			}
+		},
+	)
+	tree.OnSelected = func(uid widget.TreeNodeID) {
+		if n, ok := v.nodes[uid]; ok && !n.isFolder() {
+			m.selectRefRow(n.ref)
+		}
+	}
+	find := widget.NewEntry()
+	find.SetPlaceHolder("Find branches and tags")
+	find.OnChanged = func(s string) {
+		v.filter = s
+		m.refreshSideMenu()
+	}
+	head := widget.NewButtonWithIcon(headStatusText(m.rm), theme.HomeIcon(), m.selectHeadRow)
+	head.Alignment = widget.ButtonAlignLeading
+	head.Importance = widget.LowImportance
+	v.tree = tree
+	v.head = head
+	v.Container = container.NewBorder(
+		container.NewVBox(head, m.buildRefFilterView()), nil, nil, nil,
+		container.NewBorder(find, nil, nil, nil, tree),
+	)
 	m.sideMenuView = v
-	return v.Tree
+	return v.Container
+}
+
+// refContextMenu returns the menu of the ref whose full name is fullName.
+func (m *manager) refContextMenu(fullName string) *fyne.Menu {
+	ref := m.rm.FromFullRefName(fullName)
+	if ref == nil {
+		return nil
+	}
+	var menu *fyne.Menu
+	switch ref.RefType() {
+	case repository.Branch:
+		menu = m.branchContextMenu(ref.Name())
+	case repository.Tag:
+		menu = m.tagContextMenu(ref.Name())
+	default:
+		menu = fyne.NewMenu("")
+	}
+	if len(menu.Items) > 0 {
+		menu.Items = append(menu.Items, fyne.NewMenuItemSeparator())
+	}
+	rev := m.rm.Revision(ref)
+	menu.Items = append(menu.Items, m.refFilterMenuItems(rev)...)
+	menu.Items = append(menu.Items, fyne.NewMenuItemSeparator())
+	menu.Items = append(menu.Items, m.compareMenuItems(rev)...)
+	return menu
 }
 
-func (m *manager) selectRefRow(name string) {
+const (
+	sideMenuLocalBranches  = "Local Branches"
+	sideMenuRemoteBranches = "Remote Branches"
+	sideMenuTags           = "Tags"
+)
+
+func headStatusText(rm *repository.RepositoryManager) string {
+	head := rm.HeadRef()
+	if rm.IsDetached() {
+		if head == nil {
+			return "HEAD detached"
+		}
+		return fmt.Sprintf("HEAD detached at %s", head.TargetHash()[:7])
+	}
+	if head == nil {
+		return fmt.Sprintf("On branch %s (no commits yet)", rm.CurrentBranch())
+	}
+	return fmt.Sprintf("On branch %s", rm.CurrentBranch())
+}
+
+func (m *manager) selectHeadRow() {
+	head := m.rm.HeadRef()
+	if head == nil {
+		return
+	}
+	m.selectCommit(head.TargetHash())
+}
+
+func (m *manager) selectRefRow(fullName string) {
 	list := m.commitGraphView.List
 	if list == nil {
 		return
 	}
-	ref := m.rm.FromRefName(name)
+	ref := m.rm.FromFullRefName(fullName)
 	if ref == nil {
 		return
 	}
@@ -435,48 +766,213 @@ func ellipsisText(src string, maxWidth float32) string {
 }
 
 type patchSummaryView struct {
-	*container.Scroll
+	*fyne.Container
+	list         *widget.List
+	parentSelect *widget.Select
+
+	node    *gogigu.Node
+	parent  int
+	details []*repository.PatchFileDetail
+
+	// mu serializes the updates of the views by the listing in the background and the cancel of it.
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// apply calls f unless ctx has been canceled by another listing, and reports whether it was called.
+func (v *patchSummaryView) apply(ctx context.Context, f func()) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	f()
+	return true
 }
 
+const (
+	combinedDiffOption = "Combined"
+)
+
 func (m *manager) buildPatchSummaryView() fyne.CanvasObject {
-	v := &patchSummaryView{}
-	scroll := container.NewScroll(widget.NewLabel(""))
-	v.Scroll = scroll
+	if old := m.patchSummaryView; old != nil {
+		// the listing for the views being replaced must not update the new ones
+		old.mu.Lock()
+		if old.cancel != nil {
+			old.cancel()
+		}
+		old.mu.Unlock()
+	}
+	v := &patchSummaryView{
+		details: make([]*repository.PatchFileDetail, 0),
+	}
+	list := widget.NewList(
+		func() int {
+			return len(v.details)
+		},
+		func() fyne.CanvasObject {
+			return newContextMenuTarget(changeDetailLineItem())
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			d := v.details[id]
+			t := item.(*contextMenuTarget)
+			updateChangeDetailLine(d, t.content)
+			t.menu = func() *fyne.Menu {
+				return m.patchFileContextMenu(v.node, d)
+			}
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		m.diffView.showFileDiff(m.rm, v.details[id])
+	}
+	parentSelect := widget.NewSelect([]string{}, func(s string) {
+		if v.node == nil {
+			return
+		}
+		v.parent = parentIndexFromOption(v.parentSelect)
+		m.updateCommitViews(v.node, v.parent)
+	})
+	parentSelect.Hide()
+	v.list = list
+	v.parentSelect = parentSelect
+	v.Container = container.NewBorder(parentSelect, nil, nil, nil, list)
 	m.patchSummaryView = v
-	return v.Scroll
+	return v.Container
 }
 
-func (m *manager) updatePatchSummaryView(id widget.ListItemID) {
-	n := m.rm.Nodes[id]
-	v := m.patchSummaryView
-	details, err := m.rm.PatchFileDetails(n)
-	if err != nil {
-		v.Scroll.Content = widget.NewLabel("")
-		v.Scroll.Refresh()
+// resetParentSelect lists all the parents of the commit, even if some of them are out of the graph.
+func (v *patchSummaryView) resetParentSelect(n *gogigu.Node) {
+	v.node = n
+	v.parent = 0
+	parents := n.Commit.ParentHashes
+	if len(parents) <= 1 {
+		v.parentSelect.Hide()
 		return
 	}
-	rows := make([]fyne.CanvasObject, len(details))
-	for i, d := range details {
-		rows[i] = buildChangeDetailLine(d)
+	options := make([]string, 0, len(parents)+1)
+	for i, p := range parents {
+		options = append(options, fmt.Sprintf("Parent %d (%s)", i+1, p.String()[:7]))
 	}
-	v.Scroll.Content = container.NewVBox(rows...)
-	v.Scroll.Refresh()
+	options = append(options, combinedDiffOption)
+	v.parentSelect.Options = options
+	v.parentSelect.Selected = options[0]
+	v.parentSelect.Refresh()
+	v.parentSelect.Show()
+}
+
+func parentIndexFromOption(s *widget.Select) int {
+	if s.Selected == combinedDiffOption {
+		return repository.CombinedDiffParent
+	}
+	return s.SelectedIndex()
+}
+
+func (m *manager) updatePatchSummaryView(details []*repository.PatchFileDetail) {
+	v := m.patchSummaryView
+	v.details = details
+	v.list.UnselectAll()
+	v.list.Refresh()
+	v.list.ScrollToTop()
+	m.diffView.clear()
+}
+
+func changeDetailLineItem() fyne.CanvasObject {
+	icon := widget.NewIcon(nil)
+	name := widget.NewLabel("")
+	stat := widget.NewLabel("")
+	bar := lineStatBar()
+	return container.NewBorder(
+		nil, nil,
+		icon, container.NewHBox(stat, bar),
+		name,
+	)
+}
+
+func updateChangeDetailLine(d *repository.PatchFileDetail, item fyne.CanvasObject) {
+	objs := item.(*fyne.Container).Objects
+	objs[0].(*widget.Label).SetText(changeDetailName(d))
+	objs[1].(*widget.Icon).SetResource(changeTypeIcon(d.ChangeType()))
+	stats := objs[2].(*fyne.Container).Objects
+	stats[0].(*widget.Label).SetText(lineStatText(d))
+	updateLineStatBar(d, stats[1])
+}
+
+func changeDetailName(d *repository.PatchFileDetail) string {
+	name := d.Name()
+	if d.OldName() != d.Name() {
+		name = fmt.Sprintf("%s → %s (%d%%)", d.OldName(), d.Name(), d.Similarity())
+	}
+	if state := worktreeFileState(d); state != "" {
+		name = fmt.Sprintf("%s (%s)", name, state)
+	}
+	return name
+}
+
+func lineStatText(d *repository.PatchFileDetail) string {
+	if err := d.LineStatsError(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if !d.LineStatsCalculated() {
+		return "..."
+	}
+	if d.IsBinary() {
+		return "BIN"
+	}
+	return fmt.Sprintf("+%d -%d", d.Added(), d.Deleted())
 }
 
-func buildChangeDetailLine(d *repository.PatchFileDetail) fyne.CanvasObject {
-	var icon fyne.Resource
-	switch d.ChangeType() {
+func lineStatBar() fyne.CanvasObject {
+	blocks := make([]fyne.CanvasObject, lineStatBarBlocks)
+	for i := range blocks {
+		rect := canvas.NewRectangle(lineStatNeutralColor())
+		rect.SetMinSize(fyne.NewSize(lineStatBarBlockSize, lineStatBarBlockSize))
+		blocks[i] = rect
+	}
+	return container.NewCenter(container.NewHBox(blocks...))
+}
+
+func updateLineStatBar(d *repository.PatchFileDetail, bar fyne.CanvasObject) {
+	blocks := bar.(*fyne.Container).Objects[0].(*fyne.Container).Objects
+	added, deleted := lineStatBlocks(d)
+	for i, b := range blocks {
+		rect := b.(*canvas.Rectangle)
+		switch {
+		case i < added:
+			rect.FillColor = lineStatAddedColor()
+		case i < added+deleted:
+			rect.FillColor = lineStatDeletedColor()
+		default:
+			rect.FillColor = lineStatNeutralColor()
+		}
+		rect.Refresh()
+	}
+}
+
+func lineStatBlocks(d *repository.PatchFileDetail) (int, int) {
+	total := d.Added() + d.Deleted()
+	if d.IsBinary() || total == 0 {
+		return 0, 0
+	}
+	blocks := lineStatBarBlocks
+	if total < blocks {
+		blocks = total
+	}
+	added := int(math.Round(float64(blocks*d.Added()) / float64(total)))
+	return added, blocks - added
+}
+
+func changeTypeIcon(t repository.ChangeType) fyne.Resource {
+	switch t {
 	case repository.Modify:
-		icon = theme.MoreHorizontalIcon()
+		return theme.MoreHorizontalIcon()
 	case repository.Insert:
-		icon = theme.ContentAddIcon()
+		return theme.ContentAddIcon()
 	case repository.Delete:
-		icon = theme.ContentRemoveIcon()
+		return theme.ContentRemoveIcon()
 	case repository.Move:
-		icon = theme.NavigateNextIcon()
+		return theme.NavigateNextIcon()
+	case repository.Copy:
+		return theme.ContentCopyIcon()
 	}
-	return container.NewHBox(
-		widget.NewIcon(icon),
-		widget.NewLabel(d.Name()),
-	)
+	return nil
 }
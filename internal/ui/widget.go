@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+var _ fyne.SecondaryTappable = (*contextMenuTarget)(nil)
+
+// contextMenuTarget wraps the content to show a context menu on right click.
+// Other events are passed to the parent, so it can be used as an item of widget.List.
+type contextMenuTarget struct {
+	widget.BaseWidget
+	content fyne.CanvasObject
+	menu    func() *fyne.Menu
+}
+
+func newContextMenuTarget(content fyne.CanvasObject) *contextMenuTarget {
+	t := &contextMenuTarget{content: content}
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+func (t *contextMenuTarget) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(t.content)
+}
+
+func (t *contextMenuTarget) TappedSecondary(e *fyne.PointEvent) {
+	if t.menu == nil {
+		return
+	}
+	menu := t.menu()
+	if menu == nil {
+		return
+	}
+	c := fyne.CurrentApp().Driver().CanvasForObject(t)
+	widget.ShowPopUpMenuAtPosition(menu, c, e.AbsolutePosition)
+}
+
+var _ fyne.Tappable = (*tappableLabel)(nil)
+
+// tappableLabel is a label which handles taps by itself instead of passing them to the parent.
+type tappableLabel struct {
+	widget.Label
+	onTapped func()
+}
+
+func newTappableLabel(style fyne.TextStyle) *tappableLabel {
+	l := &tappableLabel{}
+	l.TextStyle = style
+	l.ExtendBaseWidget(l)
+	return l
+}
+
+func (l *tappableLabel) Tapped(*fyne.PointEvent) {
+	if l.onTapped != nil {
+		l.onTapped()
+	}
+}
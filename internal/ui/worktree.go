@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/lusingander/fynegit/internal/gogigu"
+	"github.com/lusingander/fynegit/internal/repository"
+)
+
+const (
+	// subjectLengthLimit is the conventional maximum length of the first line of a commit message.
+	subjectLengthLimit = 50
+)
+
+var (
+	errNothingStaged = errors.New("no changes are staged")
+)
+
+// commitDraft keeps the commit form input while the worktree views are rebuilt.
+type commitDraft struct {
+	message string
+	amend   bool
+}
+
+func (m *manager) updateWorktreeDetailView(n *gogigu.Node, details []*repository.PatchFileDetail) {
+	form := widget.NewForm()
+	form.Append("HEAD", widget.NewLabel(m.parentsShortHashes(n)))
+	changes := widget.NewLabel(changesSummary(details))
+	form.Append("Changes", changes)
+	form.Append("Staged", widget.NewLabel(stagedSummary(details)))
+	form.Append("Committer", widget.NewLabel(m.identityText()))
+
+	messageEntry := widget.NewMultiLineEntry()
+	messageEntry.Wrapping = fyne.TextWrapWord
+	messageEntry.SetPlaceHolder("Commit message")
+	messageEntry.SetText(m.draft.message)
+	subjectLength := canvas.NewText("", nil)
+	updateSubjectLengthIndicator(subjectLength, m.draft.message)
+	messageEntry.OnChanged = func(s string) {
+		m.draft.message = s
+		updateSubjectLengthIndicator(subjectLength, s)
+	}
+	form.Append("Message", messageEntry)
+
+	amendCheck := widget.NewCheck("Amend last commit", nil)
+	amendCheck.SetChecked(m.draft.amend)
+	amendCheck.OnChanged = func(b bool) {
+		m.draft.amend = b
+		if b && strings.TrimSpace(messageEntry.Text) == "" {
+			msg, err := m.rm.HeadMessage()
+			if err != nil {
+				dialog.ShowError(err, m.Window)
+				return
+			}
+			messageEntry.SetText(strings.TrimRight(msg, "\n"))
+		}
+	}
+	commitButton := widget.NewButtonWithIcon("Commit", theme.ConfirmIcon(), func() {
+		m.commit(details)
+	})
+	form.Append("", container.NewHBox(subjectLength, layout.NewSpacer(), amendCheck, commitButton))
+
+	v := m.commitDetailView
+	v.changes = changes
+	v.Scroll.Content = form
+	v.Scroll.Refresh()
+}
+
+func (m *manager) identityText() string {
+	name, email, err := m.rm.Identity()
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s %s", name, formatEmail(email))
+}
+
+func stagedCount(details []*repository.PatchFileDetail) int {
+	n := 0
+	for _, d := range details {
+		if d.HasStagedChanges() {
+			n++
+		}
+	}
+	return n
+}
+
+func stagedSummary(details []*repository.PatchFileDetail) string {
+	n := stagedCount(details)
+	files := "files"
+	if n == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s", n, files)
+}
+
+func updateSubjectLengthIndicator(t *canvas.Text, message string) {
+	n := len([]rune(strings.SplitN(message, "\n", 2)[0]))
+	t.Text = fmt.Sprintf("Subject: %d / %d", n, subjectLengthLimit)
+	t.Color = subjectLengthColor(n > subjectLengthLimit)
+	t.Refresh()
+}
+
+func (m *manager) commit(details []*repository.PatchFileDetail) {
+	if !m.draft.amend && stagedCount(details) == 0 {
+		dialog.ShowError(errNothingStaged, m.Window)
+		return
+	}
+	if err := m.rm.Commit(m.draft.message, m.draft.amend); err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	m.draft = &commitDraft{}
+	m.reloadAfterChange()
+}
+
+func (m *manager) patchFileContextMenu(n *gogigu.Node, d *repository.PatchFileDetail) *fyne.Menu {
+	if n != nil && n.IsWorktree() {
+		return m.worktreeFileContextMenu(d)
+	}
+	return m.fileContextMenu(n, d.Name())
+}
+
+func (m *manager) worktreeFileContextMenu(d *repository.PatchFileDetail) *fyne.Menu {
+	items := make([]*fyne.MenuItem, 0)
+	if d.HasUnstagedChanges() {
+		items = append(items, fyne.NewMenuItem("Stage", func() {
+			m.updateIndex(m.rm.Stage, d.Name())
+		}))
+	}
+	if d.HasStagedChanges() {
+		items = append(items, fyne.NewMenuItem("Unstage", func() {
+			m.updateIndex(m.rm.Unstage, d.Name())
+		}))
+	}
+	return fyne.NewMenu("", items...)
+}
+
+func (m *manager) updateIndex(f func(string) error, path string) {
+	if err := f(path); err != nil {
+		dialog.ShowError(err, m.Window)
+		return
+	}
+	m.refreshCommitViews()
+}
+
+func worktreeFileState(d *repository.PatchFileDetail) string {
+	switch {
+	case d.IsUntracked():
+		return "untracked"
+	case d.HasStagedChanges() && d.HasUnstagedChanges():
+		return "partially staged"
+	case d.HasStagedChanges():
+		return "staged"
+	}
+	return ""
+}
@@ -16,14 +16,17 @@ func main() {
 }
 
 func run(args []string) error {
-	repo, err := repository.OpenGitRepositoryFromArgs(args)
-	if err != nil {
-		return err
+	path := repository.RepositoryPathFromArgs(args)
+	if path != "" {
+		// fail fast before showing the window, the repository itself is loaded in the background
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
 	}
 
 	a := app.New()
 	w := a.NewWindow("")
-	ui.Start(w, repo)
+	ui.Start(w, path)
 
 	return nil
 }